@@ -0,0 +1,84 @@
+package main
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/player"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// runAPICommand sends the command to the local API of the running TrueRandomShuffle and prints the upcoming tracks it responds with
+func runAPICommand(name string, args []string) error {
+	method := http.MethodPost
+	apiURL := fmt.Sprintf("http://localhost%s/api/%s", util.AppConfig.CallbackPort, name)
+
+	switch name {
+	case "queue":
+		method = http.MethodGet
+	case "pin":
+		if len(args) == 0 {
+			return fmt.Errorf("pin requires the uri of a track from the context")
+		}
+
+		apiURL += "?uri=" + url.QueryEscape(args[0])
+	}
+
+	request, err := http.NewRequest(method, apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't create request; %s", err.Error())
+	}
+
+	request.Header.Set(player.APIHeader, "1")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("couldn't reach TrueRandomShuffle, is it running?; %s", err.Error())
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body; %s", err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("TrueRandomShuffle responded with an error %d; %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		ContextURI string `json:"contextURI"`
+		Tracks     []struct {
+			Artists []string `json:"artists"`
+			Name    string   `json:"name"`
+			URI     string   `json:"uri"`
+		} `json:"tracks"`
+	}
+
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return fmt.Errorf("couldn't unmarshal response; %s", err.Error())
+	}
+
+	fmt.Printf("Shuffling %s\n", result.ContextURI)
+
+	// the first track is the one that is playing right now
+	for index, track := range result.Tracks {
+		marker := fmt.Sprintf("%d.", index)
+		if index == 0 {
+			marker = ">"
+		}
+
+		fmt.Printf("%4s %s - %s (%s)\n", marker, track.Name, strings.Join(track.Artists, ", "), track.URI)
+	}
+
+	return nil
+}
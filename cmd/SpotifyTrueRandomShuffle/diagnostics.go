@@ -0,0 +1,139 @@
+package main
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/player"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// runStatus prints the stored token, the shuffle playlist and the current session without making any requests
+func runStatus(args []string) error {
+	token, err := auth.LoadToken()
+	if err != nil {
+		fmt.Printf("Token:            %s\n", err.Error())
+	} else {
+		fmt.Printf("Token expires:    %s\n", token.ExpirationTime().Format(time.RFC1123))
+		fmt.Printf("Token scopes:     %s\n", strings.Join(token.Scopes(), " "))
+
+		if missingScopes := token.MissingScopes(); len(missingScopes) > 0 {
+			fmt.Printf("Missing scopes:   %s (run auth again)\n", strings.Join(missingScopes, " "))
+		}
+	}
+
+	shufflePlaylist, err := getOptionalJSONData(util.AppConfig.ShufflePlaylistPath)
+	if err != nil {
+		return fmt.Errorf("couldn't get shuffle playlist JSON; %s", err.Error())
+	}
+
+	fmt.Printf("Shuffle playlist: %s\n", valueOrNone(shufflePlaylist["uri"]))
+
+	session, err := getOptionalJSONData(util.AppConfig.SessionPath)
+	if err != nil {
+		return fmt.Errorf("couldn't get session JSON; %s", err.Error())
+	}
+
+	fmt.Printf("Session context:  %s\n", valueOrNone(session["contextURI"]))
+
+	trackURIs, _ := session["shufflePlaylistTrackURIs"].([]interface{})
+	cycleTrackURIs, _ := session["cycleTrackURIs"].([]interface{})
+	fmt.Printf("Session tracks:   %d in the shuffle playlist, %d played or queued in this cycle\n", len(trackURIs), len(cycleTrackURIs))
+
+	return nil
+}
+
+// runDoctor runs every check and prints its result, it fails if any check failed
+func runDoctor(args []string) error {
+	var failed int
+
+	check := func(name string, err error, result string) {
+		if err != nil {
+			failed++
+			fmt.Printf("[FAIL] %s: %s\n", name, err.Error())
+			return
+		}
+
+		fmt.Printf("[ OK ] %s: %s\n", name, result)
+	}
+
+	// the config was already loaded before any command runs
+	check("config", nil, "loaded")
+
+	var missingEnvs []string
+
+	// the envs are checked in a fixed order, so the output is always the same
+	for _, env := range [][2]string{{"SPOTIFY_ID", util.AppConfig.ClientID}, {"SPOTIFY_SECRET", util.AppConfig.ClientSecret}, {"SPOTIFY_REDIRECT_DOMAIN", util.AppConfig.RedirectDomain}} {
+		if env[1] == "" {
+			missingEnvs = append(missingEnvs, env[0])
+		}
+	}
+
+	if len(missingEnvs) > 0 {
+		check("env", fmt.Errorf("missing %s", strings.Join(missingEnvs, ", ")), "")
+	} else {
+		check("env", nil, "all values are set")
+	}
+
+	// the callback port is taken while TrueRandomShuffle is running, so this is only a hint
+	listener, err := net.Listen("tcp", util.AppConfig.CallbackPort)
+	if err != nil {
+		check("callback port", fmt.Errorf("%s is in use, TrueRandomShuffle might already be running; %s", util.AppConfig.CallbackPort, err.Error()), "")
+	} else {
+		listener.Close()
+		check("callback port", nil, util.AppConfig.CallbackPort+" is free")
+	}
+
+	err = auth.StoredUser()
+	if err != nil {
+		check("token", err, "")
+		return fmt.Errorf("%d checks failed", failed)
+	}
+
+	if missingScopes := auth.UserToken.MissingScopes(); len(missingScopes) > 0 {
+		check("token", fmt.Errorf("missing scopes %s, run auth again", strings.Join(missingScopes, " ")), "")
+	} else {
+		check("token", nil, "has all scopes")
+	}
+
+	err = auth.UserToken.ForceRefreshToken()
+	check("token refresh", err, "works")
+
+	// checking the shuffle playlist needs a valid token
+	if err == nil {
+		shufflePlaylistURI, err := player.CheckShufflePlaylist()
+		check("shuffle playlist", err, shufflePlaylistURI)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+
+	return nil
+}
+
+// getOptionalJSONData returns the JSON data of the file or an empty map, if it doesn't exist
+func getOptionalJSONData(filePath string) (map[string]interface{}, error) {
+	if !util.FileExists(filePath) {
+		return map[string]interface{}{}, nil
+	}
+
+	return util.GetJSONData(filePath)
+}
+
+// valueOrNone returns the value as a string or "none", if it's empty or missing
+func valueOrNone(value interface{}) string {
+	stringValue, _ := value.(string)
+	if stringValue == "" {
+		return "none"
+	}
+
+	return stringValue
+}
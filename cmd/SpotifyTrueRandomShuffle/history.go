@@ -0,0 +1,102 @@
+package main
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/history"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// historyOptions are the flags of the history command
+var historyOptions struct {
+	context string
+	format  string
+	limit   int
+	outcome string
+	since   string
+	until   string
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// addHistoryFlags adds the flags of the history command to its flag set
+func addHistoryFlags(flags *flag.FlagSet) {
+	flags.StringVar(&historyOptions.context, "context", "", "only tracks played for this context uri")
+	flags.StringVar(&historyOptions.format, "format", "text", "output format: text, json (JSON Lines) or csv")
+	flags.IntVar(&historyOptions.limit, "limit", 0, "only the newest n tracks (0 for all)")
+	flags.StringVar(&historyOptions.outcome, "outcome", "", "only completed or skipped tracks")
+	flags.StringVar(&historyOptions.since, "since", "", "only tracks from this date (2006-01-02) or duration ago (24h)")
+	flags.StringVar(&historyOptions.until, "until", "", "only tracks before this date (2006-01-02) or duration ago (24h)")
+}
+
+// runHistory prints the tracks of the history that match the flags, json and csv can be redirected into a file to export them
+func runHistory(args []string) error {
+	filter := history.Filter{ContextURI: historyOptions.context, Outcome: historyOptions.outcome}
+
+	if filter.Outcome != "" && filter.Outcome != history.OutcomeCompleted && filter.Outcome != history.OutcomeSkipped {
+		return fmt.Errorf("--outcome has to be %s or %s, is '%s'", history.OutcomeCompleted, history.OutcomeSkipped, filter.Outcome)
+	}
+
+	var err error
+
+	filter.Since, err = parseHistoryTime(historyOptions.since)
+	if err != nil {
+		return fmt.Errorf("couldn't parse --since; %s", err.Error())
+	}
+
+	filter.Until, err = parseHistoryTime(historyOptions.until)
+	if err != nil {
+		return fmt.Errorf("couldn't parse --until; %s", err.Error())
+	}
+
+	records, err := history.Read(util.AppConfig.HistoryPath, filter)
+	if err != nil {
+		return fmt.Errorf("couldn't read history; %s", err.Error())
+	}
+
+	if historyOptions.limit > 0 && len(records) > historyOptions.limit {
+		records = records[len(records)-historyOptions.limit:]
+	}
+
+	if historyOptions.format != "text" {
+		return history.Export(os.Stdout, records, historyOptions.format)
+	}
+
+	for _, record := range records {
+		fmt.Printf("%s  %-9s %s/%s  %s  (%s)\n", record.Timestamp.Local().Format("2006-01-02 15:04"), record.Outcome, formatMS(record.ProgressMS), formatMS(record.DurationMS), record.TrackURI, record.ContextURI)
+	}
+
+	fmt.Printf("\n%d tracks in %s\n", len(records), util.AppConfig.HistoryPath)
+
+	return nil
+}
+
+// parseHistoryTime parses a local date or a duration before now, an empty value is the zero time
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither a date like 2006-01-02 nor a duration like 24h", value)
+	}
+
+	return date, nil
+}
+
+// formatMS formats milliseconds as minutes and seconds
+func formatMS(milliseconds int) string {
+	return fmt.Sprintf("%d:%02d", milliseconds/60000, milliseconds/1000%60)
+}
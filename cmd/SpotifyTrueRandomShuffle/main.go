@@ -3,38 +3,146 @@ package main
 // <---------------------------------------------------------------------------------------------------->
 
 import (
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/logging"
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/player"
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
 )
 
 // <---------------------------------------------------------------------------------------------------->
 
-// main is the entry into the program, it sets up our config, envs, authorizes with the user and starts the main loop
+// subcommand is a command of the CLI with its description for the usage
+type subcommand struct {
+	description string
+	run         func(args []string) error
+}
+
+var subcommands = map[string]subcommand{
+	"run":       {"authorize and start TrueRandomShuffle (default)", runLoop},
+	"auth":      {"authorize in the browser and store the token", runAuth},
+	"status":    {"show the stored token, the shuffle playlist and the current session", runStatus},
+	"reset":     {"clear and unfollow the shuffle playlist and reset the local state", runReset},
+	"doctor":    {"check the config, token and Spotify connection for problems", runDoctor},
+	"simulate":  {"poll the playback and log what would happen, without changing anything", runSimulate},
+	"queue":     {"list the upcoming tracks of the running TrueRandomShuffle", apiCommand("queue")},
+	"reshuffle": {"replace the upcoming tracks of the running TrueRandomShuffle", apiCommand("reshuffle")},
+	"pin":       {"play a track of the context next: pin <track uri>", apiCommand("pin")},
+	"history":   {"list or export the tracks played through the shuffle playlist", runHistory},
+}
+
+// commandFlags adds the flags, that only belong to one subcommand
+var commandFlags = map[string]func(flags *flag.FlagSet){
+	"history": addHistoryFlags,
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// main is the entry into the program, it picks the subcommand, parses its flags and sets up our config and envs before running it
 func main() {
-	err := util.Setup()
+	name := "run"
+	args := os.Args[1:]
+
+	// without a subcommand the flags belong to run
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	command, ok := subcommands[name]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := flags.String("config", "", "path of the config file")
+	verbose := flags.Bool("v", false, "log at debug level, with every playback event and request")
+	quiet := flags.Bool("q", false, "only log errors")
+	flags.Usage = printUsage
+
+	if addFlags, ok := commandFlags[name]; ok {
+		addFlags(flags)
+	}
+
+	flags.Parse(args)
+
+	// the flags replace the level of the config
+	level := ""
+
+	switch {
+	case *quiet:
+		level = "error"
+	case *verbose:
+		level = "debug"
+	}
+
+	err := util.Setup(*configPath)
+	if err != nil {
+		exitWithError(fmt.Errorf("couldn't setup config; %s", err.Error()))
+	}
+
+	// the other commands run next to run and would move its log file aside while it's writing to it
+	logFile, err := logging.Setup(util.AppConfig.Logging, util.AppConfig.LogPath, level, name == "run")
+	if err != nil {
+		exitWithError(fmt.Errorf("couldn't setup logging; %s", err.Error()))
+	}
+
+	err = command.run(flags.Args())
+	if err != nil {
+		slog.Error(fmt.Sprintf("couldn't %s", name), "error", err)
+		logFile.Close()
+		os.Exit(1)
+	}
+
+	logFile.Close()
+}
+
+// exitWithError logs the error and ends the program, it's only used before there is anything to clean up
+func exitWithError(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// printUsage prints all subcommands and flags
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: SpotifyTrueRandomShuffle [command] [--config path] [-v | -q] [args]\n\nCommands:\n")
+
+	for _, name := range []string{"run", "auth", "status", "reset", "doctor", "simulate", "queue", "reshuffle", "pin", "history"} {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, subcommands[name].description)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nFlags:\n  --config   path of the config file (default $TRS_CONFIG, ./configs/config.json or $XDG_CONFIG_HOME/SpotifyTrueRandomShuffle/config.json)\n  -v         log at debug level, with every playback event and request\n  -q         only log errors\n\nHistory flags:\n  --context  only tracks played for this context uri\n  --outcome  only completed or skipped tracks\n  --since    only tracks from a date (2006-01-02) or duration ago (24h)\n  --until    only tracks before a date or duration ago\n  --limit    only the newest n tracks\n  --format   text, json (JSON Lines) or csv\n")
+}
+
+// runLoop authorizes with the user and runs the main loop, until an error occurs that can't be handled
+func runLoop(args []string) error {
+	// the local API is served by the same server as the auth callback
+	player.RegisterAPI()
+
+	err := auth.User()
 	if err != nil {
-		log.Fatal(fmt.Errorf("couldn't setup config; %s", err.Error()))
+		return fmt.Errorf("couldn't authorize user; %s", err.Error())
 	}
 
-	auth.User()
+	go watchConfig()
 
 	for {
 		err = player.Start()
 		// we can't return nil, so we don't error check
-		util.LogError(fmt.Errorf("couldn't continue main loop; %s", err.Error()), false)
+		slog.Error("couldn't continue main loop", "error", err)
 
 		// check if Spotify terminated our connection
 		if strings.Contains(err.Error(), "connection reset by peer") {
 			// forcefully refresh our Token
 			forceErr := auth.UserToken.ForceRefreshToken()
 			if forceErr != nil {
-				util.LogError(forceErr, true)
+				return fmt.Errorf("couldn't force refresh token; %s", forceErr.Error())
 			}
 
 			continue
@@ -51,13 +159,59 @@ func main() {
 			// forcefully refresh our Token
 			forceErr := auth.UserToken.ForceRefreshToken()
 			if forceErr != nil {
-				util.LogError(forceErr, true)
+				return fmt.Errorf("couldn't force refresh token; %s", forceErr.Error())
 			}
 
 			continue
 		}
 
 		// if an unhandled error occured end the program
-		return
+		return err
+	}
+}
+
+// runAuth authorizes with the user and stores the token, so run doesn't need the browser
+func runAuth(args []string) error {
+	err := auth.Authorize()
+	if err != nil {
+		return fmt.Errorf("couldn't authorize user; %s", err.Error())
+	}
+
+	fmt.Printf("\nStored token at %s\n", util.AppConfig.TokenPath)
+
+	return nil
+}
+
+// runReset clears the shuffle playlist on Spotify and the local state
+func runReset(args []string) error {
+	err := auth.StoredUser()
+	if err != nil {
+		return fmt.Errorf("couldn't authorize user; %s", err.Error())
+	}
+
+	err = player.Reset()
+	if err != nil {
+		return fmt.Errorf("couldn't reset shuffle playlist; %s", err.Error())
+	}
+
+	fmt.Println("Reset the shuffle playlist and session")
+
+	return nil
+}
+
+// runSimulate logs what TrueRandomShuffle would do with the playback, until it's stopped
+func runSimulate(args []string) error {
+	err := auth.StoredUser()
+	if err != nil {
+		return fmt.Errorf("couldn't authorize user; %s", err.Error())
+	}
+
+	return player.Simulate()
+}
+
+// apiCommand returns a subcommand that sends the command to the local API of the running TrueRandomShuffle
+func apiCommand(name string) func(args []string) error {
+	return func(args []string) error {
+		return runAPICommand(name, args)
 	}
 }
@@ -0,0 +1,65 @@
+package main
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/player"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// configPollInterval is how often the config file gets checked for changes
+const configPollInterval = 2 * time.Second
+
+// <---------------------------------------------------------------------------------------------------->
+
+// watchConfig reloads the config when its file changes or on SIGHUP and passes it to the main loop, if it's valid
+func watchConfig() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastModTime := getModTime(util.ConfigPath())
+
+	for {
+		select {
+		case <-hangups:
+			slog.Info("received SIGHUP, reloading config")
+		case <-ticker.C:
+			modTime := getModTime(util.ConfigPath())
+			if modTime.Equal(lastModTime) {
+				continue
+			}
+
+			lastModTime = modTime
+			slog.Info("config file changed, reloading config", "path", util.ConfigPath())
+		}
+
+		config, err := util.LoadConfig(util.ConfigPath())
+		if err != nil {
+			slog.Error("couldn't reload config, keeping the current one", "error", err)
+			continue
+		}
+
+		player.Reload(config)
+	}
+}
+
+// getModTime returns when the file was last modified or the zero time, if it can't be read
+func getModTime(filePath string) time.Time {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return fileInfo.ModTime()
+}
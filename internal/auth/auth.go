@@ -6,7 +6,10 @@ package auth
 import (
 	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
@@ -17,7 +20,7 @@ import (
 const (
 	contentType  = "application/x-www-form-urlencoded"
 	responseType = "code"
-	scopes       = "user-read-playback-state%20user-modify-playback-state%20playlist-read-private%20playlist-read-collaborative%20playlist-modify-public%20playlist-modify-private%20user-read-private%20user-read-email"
+	scopes       = "user-read-playback-state%20user-modify-playback-state%20playlist-read-private%20playlist-read-collaborative%20playlist-modify-public%20playlist-modify-private%20user-read-private%20user-read-email%20user-library-read%20user-read-playback-position"
 
 	authURL  = "https://accounts.spotify.com/authorize?"
 	tokenURL = "https://accounts.spotify.com/api/token"
@@ -31,29 +34,81 @@ var (
 
 // <---------------------------------------------------------------------------------------------------->
 
-// User authorizes our access to the user by getting the access token from Spotify
-func User() {
-	startHTTPServer()
+// User authorizes our access to the user with the stored token, only if it's missing or lacks scopes the user has to authorize again
+func User() error {
+	err := startHTTPServer()
+	if err != nil {
+		return fmt.Errorf("couldn't start http server; %s", err.Error())
+	}
+
+	token, err := LoadToken()
+	if err == nil && len(token.MissingScopes()) == 0 {
+		UserToken = token
+		return nil
+	}
+
+	return authorize()
+}
+
+// Authorize always gets a new access token from Spotify and stores it, without doing anything else
+func Authorize() error {
+	err := startHTTPServer()
+	if err != nil {
+		return fmt.Errorf("couldn't start http server; %s", err.Error())
+	}
+
+	return authorize()
+}
+
+// StoredUser authorizes our access to the user only with the stored token, so it never needs the browser
+func StoredUser() error {
+	token, err := LoadToken()
+	if err != nil {
+		return fmt.Errorf("couldn't load token; %s", err.Error())
+	}
+
+	UserToken = token
+
+	return nil
+}
+
+// authorize lets the user accept our access in the browser and stores the token we get from Spotify
+func authorize() error {
 	requestUserAuth()
 
 	UserToken = <-tokenChannel
+
+	err := UserToken.save()
+	if err != nil {
+		return fmt.Errorf("couldn't save token; %s", err.Error())
+	}
+
+	return nil
 }
 
 // startHTTPServer starts a server that listens and severs on a callback
-func startHTTPServer() {
+func startHTTPServer() error {
 	// setup handlers
 	http.HandleFunc(util.AppConfig.CallbackPath, handleAuthCode)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 	})
 
-	// user goroutine to listen and serve server
+	// listen before serving, so a port that is already in use can be returned
+	listener, err := net.Listen("tcp", util.AppConfig.CallbackPort)
+	if err != nil {
+		return fmt.Errorf("couldn't listen on %s; %s", util.AppConfig.CallbackPort, err.Error())
+	}
+
+	// user goroutine to serve server
 	go func() {
-		err := http.ListenAndServe(util.AppConfig.CallbackPort, nil)
+		err := http.Serve(listener, nil)
 		if err != nil {
-			util.LogError(fmt.Errorf("couldn't listen and server http server; %s", err.Error()), true)
+			slog.Error("http server stopped", "error", err)
 		}
 	}()
+
+	return nil
 }
 
 // requestUserAuth prints the link required for the user auth
@@ -69,16 +124,19 @@ func handleAuthCode(w http.ResponseWriter, r *http.Request) {
 	callbackState := query.Get("state")
 
 	// compare the states
+	// the request didn't come from our link, so the user has to use it again
 	if state != callbackState {
 		http.Error(w, "state mismatch", http.StatusForbidden)
-		util.LogError(fmt.Errorf("state mismatch: %s != %s", state, callbackState), true)
+		slog.Warn("rejected auth callback with mismatching state")
+		return
 	}
 
 	// exchange the token
 	token, err := exchangeToken(query.Get("code"))
 	if err != nil {
-		http.Error(w, "couldn't get token", http.StatusForbidden)
-		util.LogError(fmt.Errorf("couldn't exchange for access token; %s", err.Error()), true)
+		http.Error(w, "couldn't get token, please click the link again", http.StatusForbidden)
+		slog.Error("couldn't exchange for access token", "error", err)
+		return
 	}
 
 	fmt.Fprintf(w, "Login Completed!")
@@ -107,5 +165,6 @@ func exchangeToken(authCode string) (Token, error) {
 		accessToken:    responseMap["access_token"].(string),
 		expirationTime: time.Now().Add(time.Duration(int(responseMap["expires_in"].(float64))-60) * time.Second),
 		refreshToken:   responseMap["refresh_token"].(string),
+		scopes:         strings.Fields(responseMap["scope"].(string)),
 	}, nil
 }
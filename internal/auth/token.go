@@ -6,6 +6,9 @@ package auth
 import (
 	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
@@ -18,10 +21,83 @@ type Token struct {
 	accessToken    string
 	expirationTime time.Time
 	refreshToken   string
+	scopes         []string
 }
 
 // <---------------------------------------------------------------------------------------------------->
 
+// LoadToken returns the token stored by a previous authorization
+func LoadToken() (*Token, error) {
+	if !util.FileExists(util.AppConfig.TokenPath) {
+		return nil, fmt.Errorf("there is no stored token (%s), authorize first", util.AppConfig.TokenPath)
+	}
+
+	tokenData, err := util.GetJSONData(util.AppConfig.TokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get token JSON; %s", err.Error())
+	}
+
+	expirationTime, err := time.Parse(time.RFC3339, tokenData["expirationTime"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse token expiration time; %s", err.Error())
+	}
+
+	token := Token{
+		accessToken:    tokenData["accessToken"].(string),
+		expirationTime: expirationTime,
+		refreshToken:   tokenData["refreshToken"].(string),
+	}
+
+	for _, scope := range tokenData["scopes"].([]interface{}) {
+		token.scopes = append(token.scopes, scope.(string))
+	}
+
+	return &token, nil
+}
+
+// ExpirationTime returns when the access token has to be refreshed
+func (token *Token) ExpirationTime() time.Time {
+	return token.expirationTime
+}
+
+// Scopes returns the scopes the user granted to the token
+func (token *Token) Scopes() []string {
+	return token.scopes
+}
+
+// MissingScopes returns all scopes TrueRandomShuffle requires, that weren't granted to the token
+func (token *Token) MissingScopes() []string {
+	var missingScopes []string
+
+	for _, scope := range strings.Split(scopes, "%20") {
+		if !slices.Contains(token.scopes, scope) {
+			missingScopes = append(missingScopes, scope)
+		}
+	}
+
+	return missingScopes
+}
+
+// save writes the token to the token JSON, so it can be used after a restart
+func (token *Token) save() error {
+	err := util.WriteJSONData(
+		util.AppConfig.TokenPath,
+		map[string]interface{}{
+			"accessToken":    token.accessToken,
+			"expirationTime": token.expirationTime.Format(time.RFC3339),
+			"refreshToken":   token.refreshToken,
+			"scopes":         token.scopes,
+		},
+		// the tokens give full access to the account, so only the user may read them
+		0600,
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't write token data to JSON; %s", err.Error())
+	}
+
+	return nil
+}
+
 // GetAccessTokenHeader generates the auth header needed fro essentially all API calls with a token
 func (token *Token) GetAccessTokenHeader() map[string]string {
 	return map[string]string{"Authorization": "Bearer " + token.getAccessToken()}
@@ -35,7 +111,8 @@ func (token *Token) getAccessToken() string {
 	if token.expirationTime.Before(currentTime) {
 		err := token.refreshAccessToken()
 		if err != nil {
-			util.LogError(fmt.Errorf("couldn't refresh access token; %s", err.Error()), true)
+			// the request with the expired token fails with an error, that reaches whoever made it
+			slog.Error("couldn't refresh access token", "error", err)
 		}
 	}
 
@@ -66,5 +143,14 @@ func (token *Token) refreshAccessToken() error {
 	token.accessToken = responseMap["access_token"].(string)
 	token.expirationTime = time.Now().Add(time.Duration(int(responseMap["expires_in"].(float64))-60) * time.Second)
 
-	return nil
+	// Spotify may rotate the refresh token, otherwise the old one stays valid
+	if refreshToken, ok := responseMap["refresh_token"].(string); ok {
+		token.refreshToken = refreshToken
+	}
+
+	if scope, ok := responseMap["scope"].(string); ok {
+		token.scopes = strings.Fields(scope)
+	}
+
+	return token.save()
 }
@@ -0,0 +1,292 @@
+// Package events derives typed playback events from successive playback states and sends them to all subscribers.
+package events
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// EventType is the kind of change between two playback states
+type EventType int
+
+const (
+	TrackStarted   EventType = iota // a new track is playing
+	TrackCompleted                  // the previous track was played until its end
+	TrackSkipped                    // the previous track was left before its end
+	Paused                          // the playback was paused or stopped
+	Resumed                         // the playback was resumed
+	ContextChanged                  // another context is playing
+	DeviceChanged                   // the playback moved to another device
+	ShuffleToggled                  // the shuffle state was toggled
+	MovedBack                       // the previous track was left for a track that was played before it
+)
+
+const (
+	// completionToleranceMS is how far from a track's end the playback may have been, for the track to still count as completed
+	completionToleranceMS = 3000
+	// recentItemsSize is how many of the last played items are remembered to detect moving back
+	recentItemsSize = 10
+)
+
+var eventTypeNames = map[EventType]string{
+	TrackStarted:   "TrackStarted",
+	TrackCompleted: "TrackCompleted",
+	TrackSkipped:   "TrackSkipped",
+	Paused:         "Paused",
+	Resumed:        "Resumed",
+	ContextChanged: "ContextChanged",
+	DeviceChanged:  "DeviceChanged",
+	ShuffleToggled: "ShuffleToggled",
+	MovedBack:      "MovedBack",
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// State holds the parts of a playback state events get derived from
+type State struct {
+	AlbumURI     string
+	ArtistURIs   []string
+	ContextURI   string
+	DeviceID     string
+	DeviceName   string
+	DurationMS   int
+	IsPlaying    bool
+	ItemURI      string
+	ProgressMS   int
+	ShuffleState bool
+	Timestamp    time.Time
+}
+
+// Event is a change between the previous and the current playback state
+type Event struct {
+	Type     EventType
+	Previous State
+	Current  State
+}
+
+// Stream diffs every new playback state against the previous one and sends the resulting events to its subscribers
+type Stream struct {
+	// maxGap is the longest time between two states, for which we can still tell how the previous track was left
+	maxGap         time.Duration
+	previous       *State
+	recentItemURIs []string
+	subscribers    []chan Event
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// String returns the name of the event type
+func (eventType EventType) String() string {
+	return eventTypeNames[eventType]
+}
+
+// String returns the event with the item it's about for logging
+func (event Event) String() string {
+	switch event.Type {
+	case TrackCompleted, TrackSkipped:
+		return fmt.Sprintf("%s (%s)", event.Type, event.Previous.ItemURI)
+	case MovedBack:
+		return fmt.Sprintf("%s (%s -> %s)", event.Type, event.Previous.ItemURI, event.Current.ItemURI)
+	case ContextChanged:
+		return fmt.Sprintf("%s (%s -> %s)", event.Type, event.Previous.ContextURI, event.Current.ContextURI)
+	case DeviceChanged:
+		return fmt.Sprintf("%s (%s -> %s)", event.Type, event.Previous.DeviceName, event.Current.DeviceName)
+	default:
+		return fmt.Sprintf("%s (%s)", event.Type, event.Current.ItemURI)
+	}
+}
+
+// LogValue returns the event with the item it's about as fields for structured logging
+func (event Event) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("type", event.Type.String())}
+
+	switch event.Type {
+	case TrackCompleted, TrackSkipped:
+		attrs = append(attrs, slog.String("track", event.Previous.ItemURI), slog.Int("progressMS", event.Previous.ProgressMS), slog.String("context", event.Previous.ContextURI))
+	case MovedBack:
+		attrs = append(attrs, slog.String("from", event.Previous.ItemURI), slog.String("track", event.Current.ItemURI), slog.String("context", event.Current.ContextURI))
+	case ContextChanged:
+		attrs = append(attrs, slog.String("from", event.Previous.ContextURI), slog.String("context", event.Current.ContextURI))
+	case DeviceChanged:
+		attrs = append(attrs, slog.String("from", event.Previous.DeviceName), slog.String("device", event.Current.DeviceName))
+	default:
+		attrs = append(attrs, slog.String("track", event.Current.ItemURI), slog.String("context", event.Current.ContextURI))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// NewState creates a State from the response of a playback state request, an empty response means nothing is playing
+func NewState(playbackResponse map[string]interface{}, timestamp time.Time) State {
+	state := State{Timestamp: timestamp}
+
+	if len(playbackResponse) == 0 {
+		return state
+	}
+
+	state.IsPlaying = playbackResponse["is_playing"].(bool)
+	state.ShuffleState = playbackResponse["shuffle_state"].(bool)
+	state.DeviceID, _ = playbackResponse["device"].(map[string]interface{})["id"].(string)
+	state.DeviceName = playbackResponse["device"].(map[string]interface{})["name"].(string)
+
+	if context, ok := playbackResponse["context"].(map[string]interface{}); ok {
+		state.ContextURI = context["uri"].(string)
+	}
+
+	if progress, ok := playbackResponse["progress_ms"].(float64); ok {
+		state.ProgressMS = int(progress)
+	}
+
+	if item, ok := playbackResponse["item"].(map[string]interface{}); ok {
+		state.ItemURI = item["uri"].(string)
+		state.DurationMS = int(item["duration_ms"].(float64))
+
+		// only tracks have an album and artists, episodes belong to a show instead
+		if album, ok := item["album"].(map[string]interface{}); ok {
+			state.AlbumURI, _ = album["uri"].(string)
+		}
+
+		artists, _ := item["artists"].([]interface{})
+		for _, artist := range artists {
+			if artistURI, ok := artist.(map[string]interface{})["uri"].(string); ok {
+				state.ArtistURIs = append(state.ArtistURIs, artistURI)
+			}
+		}
+	}
+
+	return state
+}
+
+// NewStream creates a Stream without a previous state, states further apart than the max gap don't tell how a track was left.
+// With a max gap of 0 all states get compared.
+func NewStream(maxGap time.Duration) *Stream {
+	return &Stream{maxGap: maxGap}
+}
+
+// Subscribe returns a new channel that receives all future events, if its buffer is full events for it get dropped
+func (stream *Stream) Subscribe(bufferSize int) <-chan Event {
+	subscriber := make(chan Event, bufferSize)
+	stream.subscribers = append(stream.subscribers, subscriber)
+
+	return subscriber
+}
+
+// Update diffs the new state against the previous one and sends the resulting events to all subscribers
+func (stream *Stream) Update(current State) {
+	var changes []EventType
+
+	if stream.previous == nil {
+		// on the first state we can only tell that something is playing
+		if current.ItemURI != "" && current.IsPlaying {
+			changes = append(changes, TrackStarted)
+		}
+	} else {
+		changes = diff(*stream.previous, current, stream.recentItemURIs, stream.maxGap)
+	}
+
+	for _, change := range changes {
+		stream.publish(Event{Type: change, Previous: stream.getPrevious(), Current: current})
+	}
+
+	// remember the items that were left, so we can tell if the user moves back to one of them
+	if stream.previous != nil && stream.previous.ItemURI != "" && stream.previous.ItemURI != current.ItemURI {
+		stream.recentItemURIs = append(stream.recentItemURIs, stream.previous.ItemURI)
+
+		if len(stream.recentItemURIs) > recentItemsSize {
+			stream.recentItemURIs = stream.recentItemURIs[1:]
+		}
+	}
+
+	stream.previous = &current
+}
+
+// Reset forgets the previous state and the recent items, the next state is treated like the first one
+func (stream *Stream) Reset() {
+	stream.previous = nil
+	stream.recentItemURIs = nil
+}
+
+// getPrevious returns the previous state or an empty one if there is none
+func (stream *Stream) getPrevious() State {
+	if stream.previous == nil {
+		return State{}
+	}
+
+	return *stream.previous
+}
+
+// publish sends the event to all subscribers without blocking
+func (stream *Stream) publish(event Event) {
+	for _, subscriber := range stream.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// diff returns the types of all changes between the previous and the current state in the order they happened
+func diff(previous State, current State, recentItemURIs []string, maxGap time.Duration) []EventType {
+	var changes []EventType
+
+	// device, context and shuffle changes can only be compared if there is playback in both states
+	if previous.ItemURI != "" && current.ItemURI != "" {
+		if previous.DeviceID != current.DeviceID {
+			changes = append(changes, DeviceChanged)
+		}
+
+		if previous.ContextURI != current.ContextURI {
+			changes = append(changes, ContextChanged)
+		}
+
+		if previous.ShuffleState != current.ShuffleState {
+			changes = append(changes, ShuffleToggled)
+		}
+	}
+
+	if previous.ItemURI != current.ItemURI {
+		switch {
+		// if nothing was playing before, no track was left
+		case previous.ItemURI == "":
+		// if polls were missed, the previous track could have ended or been left at any point in between
+		case previous.IsPlaying && maxGap > 0 && current.Timestamp.Sub(previous.Timestamp) > maxGap:
+		case isCompleted(previous, current):
+			changes = append(changes, TrackCompleted)
+		// leaving a track early for one that was played before it, means the user pressed previous
+		case slices.Contains(recentItemURIs, current.ItemURI):
+			changes = append(changes, MovedBack)
+		default:
+			changes = append(changes, TrackSkipped)
+		}
+
+		if current.ItemURI != "" {
+			changes = append(changes, TrackStarted)
+		}
+	}
+
+	if previous.IsPlaying && !current.IsPlaying {
+		changes = append(changes, Paused)
+	} else if !previous.IsPlaying && current.IsPlaying && previous.ItemURI == current.ItemURI {
+		changes = append(changes, Resumed)
+	}
+
+	return changes
+}
+
+// isCompleted estimates if the previous track was played until its end, based on how much time passed between both states
+func isCompleted(previous State, current State) bool {
+	expectedProgressMS := previous.ProgressMS
+
+	// the time the current track has already been playing, can't have been spent on the previous one
+	if previous.IsPlaying {
+		expectedProgressMS += int(current.Timestamp.Sub(previous.Timestamp).Milliseconds()) - current.ProgressMS
+	}
+
+	return expectedProgressMS >= previous.DurationMS-completionToleranceMS
+}
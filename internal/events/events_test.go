@@ -0,0 +1,202 @@
+package events
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// testStart is the time of the first poll in all tests
+var testStart = time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	// testBufferSize holds more events than a test produces
+	testBufferSize = 16
+	// testMaxGap is the max gap of the streams in all tests, like with a loop refresh time of a few seconds
+	testMaxGap = 2 * time.Minute
+)
+
+// poll is what the stream sees of the playback at one poll, the polls of a test share the context, device and shuffle unless they set them
+type poll struct {
+	// at is the time of the poll since testStart
+	at time.Duration
+	// item is the track that plays, if it's empty nothing plays
+	item       string
+	progress   time.Duration
+	paused     bool
+	context    string
+	device     string
+	shuffleOff bool
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// state converts the poll into the state the player would get from the playback response
+func (currentPoll poll) state() State {
+	state := State{Timestamp: testStart.Add(currentPoll.at)}
+
+	// without playback the response is empty
+	if currentPoll.item == "" {
+		return state
+	}
+
+	state.ContextURI = cmp.Or(currentPoll.context, "spotify:album:context")
+	state.DeviceID = cmp.Or(currentPoll.device, "device")
+	state.DurationMS = 180000
+	state.IsPlaying = !currentPoll.paused
+	state.ItemURI = currentPoll.item
+	state.ProgressMS = int(currentPoll.progress.Milliseconds())
+	state.ShuffleState = !currentPoll.shuffleOff
+
+	return state
+}
+
+// receive returns the types of all events the subscriber got so far
+func receive(subscriber <-chan Event) []EventType {
+	var received []EventType
+
+	for len(subscriber) > 0 {
+		received = append(received, (<-subscriber).Type)
+	}
+
+	return received
+}
+
+func TestStream(t *testing.T) {
+	second := time.Second
+
+	tests := []struct {
+		name     string
+		polls    []poll
+		expected []EventType
+	}{
+		{
+			name:     "a track keeps playing",
+			polls:    []poll{{item: "a", progress: 10 * second}, {at: 3 * second, item: "a", progress: 13 * second}},
+			expected: []EventType{TrackStarted},
+		},
+		{
+			name:     "completed",
+			polls:    []poll{{item: "a", progress: 179 * second}, {at: 2 * second, item: "b", progress: 1 * second}},
+			expected: []EventType{TrackStarted, TrackCompleted, TrackStarted},
+		},
+		{
+			name:     "completed between the polls",
+			polls:    []poll{{item: "a", progress: 175 * second}, {at: 7 * second, item: "b", progress: 2 * second}},
+			expected: []EventType{TrackStarted, TrackCompleted, TrackStarted},
+		},
+		{
+			name:     "completed inside of the tolerance",
+			polls:    []poll{{item: "a", progress: 177 * second}, {item: "b"}},
+			expected: []EventType{TrackStarted, TrackCompleted, TrackStarted},
+		},
+		{
+			name:     "time spent on the next track doesn't count",
+			polls:    []poll{{item: "a", progress: 170 * second}, {at: 7 * second, item: "b", progress: 5 * second}},
+			expected: []EventType{TrackStarted, TrackSkipped, TrackStarted},
+		},
+		{
+			name:     "skipped",
+			polls:    []poll{{item: "a", progress: 60 * second}, {at: 2 * second, item: "b", progress: 1 * second}},
+			expected: []EventType{TrackStarted, TrackSkipped, TrackStarted},
+		},
+		{
+			name: "moved back",
+			polls: []poll{
+				{item: "a", progress: 60 * second},
+				{at: 2 * second, item: "b", progress: 1 * second},
+				{at: 4 * second, item: "a", progress: 1 * second},
+			},
+			expected: []EventType{TrackStarted, TrackSkipped, TrackStarted, MovedBack, TrackStarted},
+		},
+		{
+			name: "completed before a recent track isn't moving back",
+			polls: []poll{
+				{item: "a", progress: 60 * second},
+				{at: 2 * second, item: "b", progress: 1 * second},
+				{at: 180 * second, item: "b", progress: 179 * second},
+				{at: 182 * second, item: "a", progress: 1 * second},
+			},
+			expected: []EventType{TrackStarted, TrackSkipped, TrackStarted, TrackCompleted, TrackStarted},
+		},
+		{
+			name:     "started from nothing",
+			polls:    []poll{{}, {at: 2 * second, item: "a", progress: 1 * second}},
+			expected: []EventType{TrackStarted},
+		},
+		{
+			name:     "stopped",
+			polls:    []poll{{item: "a", progress: 60 * second}, {at: 2 * second}},
+			expected: []EventType{TrackStarted, TrackSkipped, Paused},
+		},
+		{
+			name:     "paused",
+			polls:    []poll{{item: "a", progress: 60 * second}, {at: 1 * second, item: "a", progress: 61 * second, paused: true}},
+			expected: []EventType{TrackStarted, Paused},
+		},
+		{
+			name:     "resumed",
+			polls:    []poll{{item: "a", progress: 60 * second, paused: true}, {at: 30 * second, item: "a", progress: 61 * second}},
+			expected: []EventType{Resumed},
+		},
+		{
+			name:     "a paused track doesn't progress",
+			polls:    []poll{{item: "a", progress: 170 * second, paused: true}, {at: 30 * second, item: "b"}},
+			expected: []EventType{TrackSkipped, TrackStarted},
+		},
+		{
+			name:     "missed polls don't tell how a track was left",
+			polls:    []poll{{item: "a", progress: 60 * second}, {at: 3 * time.Hour, item: "b", progress: 1 * second}},
+			expected: []EventType{TrackStarted, TrackStarted},
+		},
+		{
+			name:     "a paused track was left, no matter how long ago",
+			polls:    []poll{{item: "a", progress: 60 * second, paused: true}, {at: 3 * time.Hour, item: "b", progress: 1 * second}},
+			expected: []EventType{TrackSkipped, TrackStarted},
+		},
+		{
+			name: "device, context and shuffle changed",
+			polls: []poll{
+				{item: "a", progress: 60 * second},
+				{at: 1 * second, item: "a", progress: 61 * second, context: "spotify:playlist:other", device: "other", shuffleOff: true},
+			},
+			expected: []EventType{TrackStarted, DeviceChanged, ContextChanged, ShuffleToggled},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := NewStream(testMaxGap)
+			subscriber := stream.Subscribe(testBufferSize)
+
+			for _, currentPoll := range test.polls {
+				stream.Update(currentPoll.state())
+			}
+
+			if received := receive(subscriber); !slices.Equal(received, test.expected) {
+				t.Errorf("got %v, expected %v", received, test.expected)
+			}
+		})
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	// without a max gap only the reset keeps the old state from being compared
+	stream := NewStream(0)
+	subscriber := stream.Subscribe(testBufferSize)
+
+	// a track near its end, before the player stops following the playback for hours
+	stream.Update(poll{item: "a", progress: 170 * time.Second}.state())
+	stream.Reset()
+	stream.Update(poll{at: 3 * time.Hour, item: "b", progress: 1 * time.Second}.state())
+
+	// the first update starts "a", after the reset "b" can only be started
+	if received, expected := receive(subscriber), []EventType{TrackStarted, TrackStarted}; !slices.Equal(received, expected) {
+		t.Errorf("got %v, expected %v", received, expected)
+	}
+}
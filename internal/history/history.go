@@ -0,0 +1,199 @@
+// Package history keeps an append-only journal of every track played through the shuffle playlist, stored as JSON Lines.
+package history
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+const (
+	OutcomeCompleted = "completed" // the track was played until its end
+	OutcomeSkipped   = "skipped"   // the track was left before its end
+)
+
+// maxRecordSize is the longest line we read from the journal, a record is far shorter
+const maxRecordSize = 1024 * 1024
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Record is a track that was played, the progress is the last one observed before the track was left
+type Record struct {
+	Timestamp  time.Time `json:"timestamp"`
+	TrackURI   string    `json:"trackURI"`
+	ArtistURIs []string  `json:"artistURIs"`
+	AlbumURI   string    `json:"albumURI"`
+	ContextURI string    `json:"contextURI"`
+	Device     string    `json:"device"`
+	Outcome    string    `json:"outcome"`
+	ProgressMS int       `json:"progressMS"`
+	DurationMS int       `json:"durationMS"`
+}
+
+// Filter selects records, empty values don't filter
+type Filter struct {
+	ContextURI string
+	Outcome    string
+	Since      time.Time
+	Until      time.Time
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Append adds the record as a new line to the end of the journal, which gets created if it doesn't exist
+func Append(journalPath string, record Record) error {
+	jsonRecord, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal history record; %s", err.Error())
+	}
+
+	journalFile, err := os.OpenFile(journalPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open history (%s); %s", journalPath, err.Error())
+	}
+	defer journalFile.Close()
+
+	fileInfo, err := journalFile.Stat()
+	if err != nil {
+		return fmt.Errorf("couldn't stat history (%s); %s", journalPath, err.Error())
+	}
+
+	// a line that was cut off by a crash gets ended, so the new record starts on its own line
+	if fileInfo.Size() > 0 {
+		lastByte := make([]byte, 1)
+
+		_, err = journalFile.ReadAt(lastByte, fileInfo.Size()-1)
+		if err != nil {
+			return fmt.Errorf("couldn't read end of history (%s); %s", journalPath, err.Error())
+		}
+
+		if lastByte[0] != '\n' {
+			jsonRecord = append([]byte{'\n'}, jsonRecord...)
+		}
+	}
+
+	_, err = journalFile.Write(append(jsonRecord, '\n'))
+	if err != nil {
+		return fmt.Errorf("couldn't write to history (%s); %s", journalPath, err.Error())
+	}
+
+	// the record should survive a crash right after it was played
+	err = journalFile.Sync()
+	if err != nil {
+		return fmt.Errorf("couldn't sync history (%s); %s", journalPath, err.Error())
+	}
+
+	return nil
+}
+
+// Read returns all records of the journal that match the filter from oldest to newest, a missing journal has no records
+func Read(journalPath string, filter Filter) ([]Record, error) {
+	var records []Record
+
+	journalFile, err := os.Open(journalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return records, fmt.Errorf("couldn't open history (%s); %s", journalPath, err.Error())
+	}
+	defer journalFile.Close()
+
+	scanner := bufio.NewScanner(journalFile)
+	scanner.Buffer(make([]byte, 0, 4096), maxRecordSize)
+
+	for scanner.Scan() {
+		var record Record
+
+		// a line that was cut off by a crash is skipped, instead of making the whole journal unreadable
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return records, fmt.Errorf("couldn't read history (%s); %s", journalPath, err.Error())
+	}
+
+	return records, nil
+}
+
+// Export writes the records as JSON Lines ("json") or as CSV with a header ("csv")
+func Export(writer io.Writer, records []Record, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(writer)
+
+		for _, record := range records {
+			err := encoder.Encode(record)
+			if err != nil {
+				return fmt.Errorf("couldn't encode history record; %s", err.Error())
+			}
+		}
+	case "csv":
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Write([]string{"timestamp", "trackURI", "artistURIs", "albumURI", "contextURI", "device", "outcome", "progressMS", "durationMS"})
+
+		for _, record := range records {
+			csvWriter.Write([]string{
+				record.Timestamp.Format(time.RFC3339),
+				record.TrackURI,
+				strings.Join(record.ArtistURIs, " "),
+				record.AlbumURI,
+				record.ContextURI,
+				record.Device,
+				record.Outcome,
+				strconv.Itoa(record.ProgressMS),
+				strconv.Itoa(record.DurationMS),
+			})
+		}
+
+		// the writer keeps the first error, so it's enough to check it once at the end
+		csvWriter.Flush()
+
+		err := csvWriter.Error()
+		if err != nil {
+			return fmt.Errorf("couldn't write history CSV; %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("'%s' isn't an export format, only json and csv are possible", format)
+	}
+
+	return nil
+}
+
+// matches checks if the record fulfills every condition of the filter
+func (filter Filter) matches(record Record) bool {
+	if filter.ContextURI != "" && record.ContextURI != filter.ContextURI {
+		return false
+	}
+
+	if filter.Outcome != "" && record.Outcome != filter.Outcome {
+		return false
+	}
+
+	if !filter.Since.IsZero() && record.Timestamp.Before(filter.Since) {
+		return false
+	}
+
+	if !filter.Until.IsZero() && !record.Timestamp.Before(filter.Until) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,177 @@
+package history
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// testJournal are the records of the journal in all tests, from oldest to newest
+var testJournal = []Record{
+	{Timestamp: time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC), TrackURI: "spotify:track:a", ContextURI: "spotify:album:morning", Outcome: OutcomeCompleted},
+	{Timestamp: time.Date(2026, time.January, 1, 9, 3, 0, 0, time.UTC), TrackURI: "spotify:track:b", ContextURI: "spotify:album:morning", Outcome: OutcomeSkipped},
+	{Timestamp: time.Date(2026, time.January, 2, 20, 0, 0, 0, time.UTC), TrackURI: "spotify:track:c", ContextURI: "spotify:playlist:evening", Outcome: OutcomeCompleted},
+	{Timestamp: time.Date(2026, time.January, 3, 20, 0, 0, 0, time.UTC), TrackURI: "spotify:track:d", ContextURI: "spotify:playlist:evening", Outcome: OutcomeSkipped},
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// writeTestJournal appends all records of the test journal to a new journal and returns its path
+func writeTestJournal(t *testing.T) string {
+	t.Helper()
+
+	journalPath := filepath.Join(t.TempDir(), "history.jsonl")
+
+	for _, record := range testJournal {
+		err := Append(journalPath, record)
+		if err != nil {
+			t.Fatalf("Append returned an error: %s", err.Error())
+		}
+	}
+
+	return journalPath
+}
+
+// getTrackURIs returns the track of every record in order
+func getTrackURIs(records []Record) []string {
+	var trackURIs []string
+
+	for _, record := range records {
+		trackURIs = append(trackURIs, record.TrackURI)
+	}
+
+	return trackURIs
+}
+
+func TestReadMissingJournal(t *testing.T) {
+	records, err := Read(filepath.Join(t.TempDir(), "history.jsonl"), Filter{})
+	if err != nil {
+		t.Fatalf("Read returned an error: %s", err.Error())
+	}
+
+	if len(records) != 0 {
+		t.Errorf("got %d records, expected none", len(records))
+	}
+}
+
+func TestAppendAfterCrash(t *testing.T) {
+	journalPath := writeTestJournal(t)
+
+	// a crash while writing leaves the last line without its end
+	journalFile, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("couldn't open journal: %s", err.Error())
+	}
+
+	journalFile.WriteString(`{"timestamp": "2026-01-04T`)
+	journalFile.Close()
+
+	err = Append(journalPath, Record{Timestamp: time.Date(2026, time.January, 4, 8, 0, 0, 0, time.UTC), TrackURI: "spotify:track:e"})
+	if err != nil {
+		t.Fatalf("Append returned an error: %s", err.Error())
+	}
+
+	records, err := Read(journalPath, Filter{})
+	if err != nil {
+		t.Fatalf("Read returned an error: %s", err.Error())
+	}
+
+	// the cut off record is lost, but the records around it are kept
+	if trackURIs, expected := getTrackURIs(records), []string{"spotify:track:a", "spotify:track:b", "spotify:track:c", "spotify:track:d", "spotify:track:e"}; !slices.Equal(trackURIs, expected) {
+		t.Errorf("got %v, expected %v", trackURIs, expected)
+	}
+}
+
+func TestReadFilter(t *testing.T) {
+	journalPath := writeTestJournal(t)
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "everything", expected: []string{"spotify:track:a", "spotify:track:b", "spotify:track:c", "spotify:track:d"}},
+		{name: "context", filter: Filter{ContextURI: "spotify:playlist:evening"}, expected: []string{"spotify:track:c", "spotify:track:d"}},
+		{name: "outcome", filter: Filter{Outcome: OutcomeSkipped}, expected: []string{"spotify:track:b", "spotify:track:d"}},
+		{name: "since is inclusive", filter: Filter{Since: testJournal[1].Timestamp}, expected: []string{"spotify:track:b", "spotify:track:c", "spotify:track:d"}},
+		{name: "until is exclusive", filter: Filter{Until: testJournal[2].Timestamp}, expected: []string{"spotify:track:a", "spotify:track:b"}},
+		{name: "all conditions", filter: Filter{ContextURI: "spotify:album:morning", Outcome: OutcomeCompleted, Since: testJournal[0].Timestamp, Until: testJournal[3].Timestamp}, expected: []string{"spotify:track:a"}},
+		{name: "nothing matches", filter: Filter{ContextURI: "spotify:album:other"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := Read(journalPath, test.filter)
+			if err != nil {
+				t.Fatalf("Read returned an error: %s", err.Error())
+			}
+
+			if trackURIs := getTrackURIs(records); !slices.Equal(trackURIs, test.expected) {
+				t.Errorf("got %v, expected %v", trackURIs, test.expected)
+			}
+		})
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	records := []Record{{
+		Timestamp:  time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC),
+		TrackURI:   "spotify:track:a",
+		ArtistURIs: []string{"spotify:artist:x", "spotify:artist:y"},
+		AlbumURI:   "spotify:album:z",
+		ContextURI: "spotify:playlist:p",
+		Device:     "Kitchen, Speaker",
+		Outcome:    OutcomeSkipped,
+		ProgressMS: 61000,
+		DurationMS: 180000,
+	}}
+
+	var output bytes.Buffer
+
+	err := Export(&output, records, "csv")
+	if err != nil {
+		t.Fatalf("Export returned an error: %s", err.Error())
+	}
+
+	// the artists are separated by spaces, so a record stays one column per value
+	expected := "timestamp,trackURI,artistURIs,albumURI,contextURI,device,outcome,progressMS,durationMS\n" +
+		"2026-01-01T09:00:00Z,spotify:track:a,spotify:artist:x spotify:artist:y,spotify:album:z,spotify:playlist:p,\"Kitchen, Speaker\",skipped,61000,180000\n"
+
+	if output.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", output.String(), expected)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	journalPath := writeTestJournal(t)
+
+	var output bytes.Buffer
+
+	err := Export(&output, testJournal, "json")
+	if err != nil {
+		t.Fatalf("Export returned an error: %s", err.Error())
+	}
+
+	// the JSON export has the same lines as the journal, so it can be read like one
+	journal, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("couldn't read journal: %s", err.Error())
+	}
+
+	if output.String() != string(journal) {
+		t.Errorf("got\n%s\nexpected\n%s", output.String(), journal)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	if err := Export(&bytes.Buffer{}, testJournal, "xml"); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
@@ -0,0 +1,108 @@
+// Package logging sets up the structured logger of the program, which writes leveled records to the console and a rotating log file.
+package logging
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+const redacted = "[REDACTED]"
+
+var (
+	// Formats are all formats a log record can be written in
+	Formats = []string{"text", "json"}
+	// levels are all levels a record can be logged at, by the name used in the config
+	levels = map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	// secretKeys are the keys of attributes, whose values never get logged
+	secretKeys = []string{"access_token", "accesstoken", "authorization", "client_secret", "code", "refresh_token", "refreshtoken", "secret", "token"}
+	// secretPattern matches credentials inside of a text, like an Authorization header in an error message
+	secretPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Config is the layout of the logging settings in config.json, a max of 0 turns that rotation off
+type Config struct {
+	Format     string `json:"format"`
+	Level      string `json:"level"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+	MaxSizeMB  int    `json:"maxSizeMB"`
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Setup makes a logger with the config, that writes to stderr and the log file, the default for the whole program.
+// A non empty level replaces the one of the config. Only the process that rotates the log file may move it aside and delete old ones,
+// all others only append to it. The returned closer closes the log file.
+func Setup(config Config, logPath string, level string, rotate bool) (io.Closer, error) {
+	if level == "" {
+		level = config.Level
+	}
+
+	slogLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	// without a max age and size the file never gets moved aside
+	if !rotate {
+		config.MaxAgeDays, config.MaxSizeMB = 0, 0
+	}
+
+	logFile, err := openRotatingFile(logPath, config.MaxSizeMB, config.MaxAgeDays)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open log file; %s", err.Error())
+	}
+
+	output := io.MultiWriter(os.Stderr, logFile)
+	options := &slog.HandlerOptions{Level: slogLevel, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler = slog.NewTextHandler(output, options)
+	if config.Format == "json" {
+		handler = slog.NewJSONHandler(output, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return logFile, nil
+}
+
+// ParseLevel returns the level with the provided name
+func ParseLevel(level string) (slog.Level, error) {
+	slogLevel, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return slogLevel, fmt.Errorf("'%s' isn't a log level, only debug, info, warn and error are possible", level)
+	}
+
+	return slogLevel, nil
+}
+
+// redactAttr replaces the values of secret attributes and removes credentials from all texts, before a record gets written
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if slices.Contains(secretKeys, strings.ToLower(attr.Key)) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case string:
+		return slog.String(attr.Key, secretPattern.ReplaceAllString(value, "$1 "+redacted))
+	case error:
+		return slog.String(attr.Key, secretPattern.ReplaceAllString(value.Error(), "$1 "+redacted))
+	}
+
+	return attr
+}
@@ -0,0 +1,45 @@
+package logging
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestRedactAttr(t *testing.T) {
+	tests := []struct {
+		name     string
+		attr     slog.Attr
+		expected string
+	}{
+		{name: "secret key", attr: slog.String("refresh_token", "abc"), expected: redacted},
+		{name: "secret key in another case", attr: slog.String("accessToken", "abc"), expected: redacted},
+		{name: "secret key with another type", attr: slog.Int("code", 1234), expected: redacted},
+		{name: "bearer token in a text", attr: slog.String("header", "Authorization: Bearer abc.DEF-123"), expected: "Authorization: Bearer " + redacted},
+		{name: "basic credentials in an error", attr: slog.Any("error", errors.New("request with basic YWJjOmRlZg== failed")), expected: "request with basic " + redacted + " failed"},
+		{name: "a word that only starts like bearer", attr: slog.String("message", "Bearers of good news"), expected: "Bearers of good news"},
+		{name: "harmless text", attr: slog.String("context", "spotify:album:id"), expected: "spotify:album:id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attr := redactAttr(nil, test.attr)
+
+			if attr.Key != test.attr.Key || attr.Value.String() != test.expected {
+				t.Errorf("got %s=%s, expected %s=%s", attr.Key, attr.Value.String(), test.attr.Key, test.expected)
+			}
+		})
+	}
+}
+
+func TestRedactAttrKeepsOtherValues(t *testing.T) {
+	attr := slog.Int("status", 401)
+
+	if redactedAttr := redactAttr(nil, attr); !redactedAttr.Equal(attr) {
+		t.Errorf("got %s, expected %s", redactedAttr, attr)
+	}
+}
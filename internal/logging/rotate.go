@@ -0,0 +1,170 @@
+// Package logging sets up the structured logger of the program, which writes leveled records to the console and a rotating log file.
+package logging
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// rotatedTimeFormat is the format of the time in the name of a rotated log file, so the names sort by age
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// <---------------------------------------------------------------------------------------------------->
+
+// rotatingFile is a log file that stays open and gets moved aside once it's too big or too old, old moved files get deleted
+type rotatingFile struct {
+	mutex sync.Mutex
+
+	// file is nil, if it couldn't be opened again after a rotation
+	file   *os.File
+	path   string
+	size   int64
+	maxAge time.Duration
+	// maxSize is the size in bytes after which the file gets rotated
+	maxSize int64
+	// started is when the file was first written to, it's moved aside once it's older than the max age
+	started time.Time
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// openRotatingFile opens the log file at the path for appending and deletes rotated files that are too old
+func openRotatingFile(path string, maxSizeMB int, maxAgeDays int) (*rotatingFile, error) {
+	logFile := &rotatingFile{
+		path:    path,
+		maxAge:  time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxSize: int64(maxSizeMB) * 1024 * 1024,
+	}
+
+	err := logFile.open()
+	if err != nil {
+		return nil, err
+	}
+
+	logFile.removeOldFiles()
+
+	return logFile, nil
+}
+
+// Write writes the record to the file and rotates it first, if the record wouldn't fit anymore or the file is too old
+func (logFile *rotatingFile) Write(record []byte) (int, error) {
+	logFile.mutex.Lock()
+	defer logFile.mutex.Unlock()
+
+	// a file that couldn't be opened again after a rotation is tried again with every record
+	if logFile.file == nil {
+		err := logFile.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	tooBig := logFile.maxSize > 0 && logFile.size+int64(len(record)) > logFile.maxSize
+	tooOld := logFile.maxAge > 0 && time.Since(logFile.started) >= logFile.maxAge
+
+	if logFile.size > 0 && (tooBig || tooOld) {
+		// if only moving the file aside failed, the record still gets written to it and the rotation is tried again with the next one
+		err := logFile.rotate()
+		if err != nil && logFile.file == nil {
+			return 0, err
+		}
+	}
+
+	written, err := logFile.file.Write(record)
+	logFile.size += int64(written)
+
+	return written, err
+}
+
+// Close closes the file, records written afterwards are lost
+func (logFile *rotatingFile) Close() error {
+	logFile.mutex.Lock()
+	defer logFile.mutex.Unlock()
+
+	if logFile.file == nil {
+		return nil
+	}
+
+	return logFile.file.Close()
+}
+
+// open opens the file at the path and continues its size
+func (logFile *rotatingFile) open() error {
+	file, err := os.OpenFile(logFile.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s; %s", logFile.path, err.Error())
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("couldn't stat %s; %s", logFile.path, err.Error())
+	}
+
+	logFile.file = file
+	logFile.size = fileInfo.Size()
+	logFile.started = time.Now()
+
+	// we don't know when the records of an existing file were written, its last change is the closest we have
+	if logFile.size > 0 {
+		logFile.started = fileInfo.ModTime()
+	}
+
+	return nil
+}
+
+// rotate moves the current file aside with the current time in its name and continues in a new file,
+// if that fails the file at the path is opened again, so records never get written to a closed file
+func (logFile *rotatingFile) rotate() error {
+	err := logFile.file.Close()
+	logFile.file = nil
+
+	if err != nil {
+		return errors.Join(fmt.Errorf("couldn't close %s; %s", logFile.path, err.Error()), logFile.open())
+	}
+
+	now := time.Now()
+	extension := filepath.Ext(logFile.path)
+	rotatedPath := fmt.Sprintf("%s.%s%s", strings.TrimSuffix(logFile.path, extension), now.Format(rotatedTimeFormat), extension)
+
+	err = os.Rename(logFile.path, rotatedPath)
+	if err != nil {
+		return errors.Join(fmt.Errorf("couldn't rename %s; %s", logFile.path, err.Error()), logFile.open())
+	}
+
+	// a file that was last written to long ago keeps that time when it's renamed, but it should only be deleted the max age after it was moved aside
+	os.Chtimes(rotatedPath, now, now)
+
+	logFile.removeOldFiles()
+
+	return logFile.open()
+}
+
+// removeOldFiles deletes all rotated files, that were moved aside before the max age
+func (logFile *rotatingFile) removeOldFiles() {
+	if logFile.maxAge <= 0 {
+		return
+	}
+
+	extension := filepath.Ext(logFile.path)
+	rotatedPaths, _ := filepath.Glob(strings.TrimSuffix(logFile.path, extension) + ".*" + extension)
+
+	for _, rotatedPath := range rotatedPaths {
+		fileInfo, err := os.Stat(rotatedPath)
+		if err != nil || rotatedPath == logFile.path || time.Since(fileInfo.ModTime()) < logFile.maxAge {
+			continue
+		}
+
+		// a file we can't delete will be tried again on the next rotation
+		os.Remove(rotatedPath)
+	}
+}
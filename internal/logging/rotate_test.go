@@ -0,0 +1,150 @@
+package logging
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// testRecord is a record of 10 bytes
+const testRecord = "new record"
+
+// <---------------------------------------------------------------------------------------------------->
+
+// getRotatedPaths returns the rotated files next to the log file
+func getRotatedPaths(t *testing.T, logPath string) []string {
+	t.Helper()
+
+	rotatedPaths, err := filepath.Glob(filepath.Join(filepath.Dir(logPath), "trs.*.log"))
+	if err != nil {
+		t.Fatalf("couldn't glob rotated files: %s", err.Error())
+	}
+
+	return rotatedPaths
+}
+
+func TestRotatingFileWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing is the content of the log file before it gets opened
+		existing        string
+		lastChange      time.Duration
+		maxSize         int64
+		maxAge          time.Duration
+		expectedRotated bool
+	}{
+		{name: "record doesn't fit anymore", existing: "old record", maxSize: 16, expectedRotated: true},
+		{name: "record fits", existing: "old", maxSize: 16, expectedRotated: false},
+		{name: "record is bigger than an empty file may be", maxSize: 4, expectedRotated: false},
+		{name: "last changed before the max age", existing: "old record", lastChange: 48 * time.Hour, maxAge: 24 * time.Hour, expectedRotated: true},
+		{name: "last changed inside of the max age", existing: "old record", lastChange: time.Hour, maxAge: 24 * time.Hour, expectedRotated: false},
+		{name: "without a max age and size", existing: "old record", lastChange: 48 * time.Hour, expectedRotated: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "trs.log")
+
+			err := os.WriteFile(logPath, []byte(test.existing), 0644)
+			if err != nil {
+				t.Fatalf("couldn't write log file: %s", err.Error())
+			}
+
+			lastChange := time.Now().Add(-test.lastChange)
+
+			err = os.Chtimes(logPath, lastChange, lastChange)
+			if err != nil {
+				t.Fatalf("couldn't change the times of the log file: %s", err.Error())
+			}
+
+			logFile, err := openRotatingFile(logPath, 0, 0)
+			if err != nil {
+				t.Fatalf("openRotatingFile returned an error: %s", err.Error())
+			}
+			defer logFile.Close()
+
+			logFile.maxSize = test.maxSize
+			logFile.maxAge = test.maxAge
+
+			_, err = logFile.Write([]byte(testRecord))
+			if err != nil {
+				t.Fatalf("Write returned an error: %s", err.Error())
+			}
+
+			rotatedPaths := getRotatedPaths(t, logPath)
+			if rotated := len(rotatedPaths) > 0; rotated != test.expectedRotated {
+				t.Fatalf("got rotated %t, expected %t", rotated, test.expectedRotated)
+			}
+
+			expectedContent := test.existing + testRecord
+
+			// the old records are moved aside and the new one starts the new file
+			if test.expectedRotated {
+				expectedContent = testRecord
+
+				if rotatedContent, _ := os.ReadFile(rotatedPaths[0]); string(rotatedContent) != test.existing {
+					t.Errorf("the rotated file has %q, expected %q", rotatedContent, test.existing)
+				}
+			}
+
+			if content, _ := os.ReadFile(logPath); string(content) != expectedContent {
+				t.Errorf("the log file has %q, expected %q", content, expectedContent)
+			}
+		})
+	}
+}
+
+func TestOpenRotatingFileRemovesOldFiles(t *testing.T) {
+	logDir := t.TempDir()
+	logPath := filepath.Join(logDir, "trs.log")
+
+	files := map[string]time.Duration{
+		"trs.log":                           72 * time.Hour,
+		"trs.2026-01-01T12-00-00.000.log":   72 * time.Hour,
+		"trs.2026-01-03T12-00-00.000.log":   time.Hour,
+		"other.2026-01-01T12-00-00.000.log": 72 * time.Hour,
+	}
+
+	for name, lastChange := range files {
+		path := filepath.Join(logDir, name)
+
+		err := os.WriteFile(path, []byte("record"), 0644)
+		if err != nil {
+			t.Fatalf("couldn't write %s: %s", name, err.Error())
+		}
+
+		err = os.Chtimes(path, time.Now().Add(-lastChange), time.Now().Add(-lastChange))
+		if err != nil {
+			t.Fatalf("couldn't change the times of %s: %s", name, err.Error())
+		}
+	}
+
+	logFile, err := openRotatingFile(logPath, 0, 1)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned an error: %s", err.Error())
+	}
+	defer logFile.Close()
+
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		t.Fatalf("couldn't read log directory: %s", err.Error())
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	// only rotated files of this log are deleted, the log file itself is only rotated when it's written to
+	expected := []string{"other.2026-01-01T12-00-00.000.log", "trs.2026-01-03T12-00-00.000.log", "trs.log"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("got %v, expected %v", names, expected)
+	}
+}
@@ -0,0 +1,361 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// APIHeader has to be set on every request to the local API, browsers can't send it to another origin without asking first,
+// so a website can't use the API, even though it's reachable from the browser
+const APIHeader = "X-TrueRandomShuffle"
+
+// commandTimeout is how long the local API waits for the main loop to execute a command
+const commandTimeout = 30 * time.Second
+
+// command is a request from the local API, that gets executed by the main loop between two polls
+type command struct {
+	name  string
+	uri   string
+	reply chan commandResult
+}
+
+// commandResult is the response to a command
+type commandResult struct {
+	data map[string]interface{}
+	err  error
+}
+
+var commands = make(chan command)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// RegisterAPI adds the handlers of the local API to the HTTP server, that also receives the auth callback
+func RegisterAPI() {
+	http.HandleFunc("/api/queue", handleCommand("queue", http.MethodGet))
+	http.HandleFunc("/api/reshuffle", handleCommand("reshuffle", http.MethodPost))
+	http.HandleFunc("/api/pin", handleCommand("pin", http.MethodPost))
+}
+
+// handleCommand returns a handler that passes the command on to the main loop and responds with its result as JSON
+func handleCommand(name string, method string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the server listens on all interfaces, but the API may only be used locally
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil || !net.ParseIP(host).IsLoopback() {
+			http.Error(w, "the API is only available locally", http.StatusForbidden)
+			return
+		}
+
+		if r.Header.Get(APIHeader) == "" {
+			http.Error(w, fmt.Sprintf("the API requires the %s header", APIHeader), http.StatusForbidden)
+			return
+		}
+
+		if r.Method != method {
+			http.Error(w, fmt.Sprintf("'%s' isn't allowed, use %s", r.Method, method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		newCommand := command{name: name, uri: r.URL.Query().Get("uri"), reply: make(chan commandResult, 1)}
+
+		select {
+		case commands <- newCommand:
+		case <-time.After(commandTimeout):
+			http.Error(w, "the main loop isn't running", http.StatusServiceUnavailable)
+			return
+		}
+
+		var result commandResult
+
+		select {
+		case result = <-newCommand.reply:
+		case <-time.After(commandTimeout):
+			http.Error(w, "the main loop didn't respond in time", http.StatusGatewayTimeout)
+			return
+		}
+
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result.data)
+	}
+}
+
+// serveCommands executes all commands from the local API and applies reloaded configs, until the wait time is over
+func (player *Player) serveCommands(waitTime time.Duration) {
+	timer := time.NewTimer(waitTime)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return
+		case newCommand := <-commands:
+			newCommand.reply <- player.executeCommand(newCommand)
+		case config := <-configReloads:
+			player.applyConfig(config)
+		}
+	}
+}
+
+// executeCommand runs the command on the player and returns its result
+func (player *Player) executeCommand(newCommand command) commandResult {
+	// all commands work on the shuffle playlist, so it has to be playing
+	if player.state != stateShuffling {
+		return commandResult{err: fmt.Errorf("the shuffle playlist isn't playing right now (%s)", player.state)}
+	}
+
+	var err error
+
+	switch newCommand.name {
+	case "reshuffle":
+		err = player.reshuffle()
+	case "pin":
+		err = player.pinTrack(newCommand.uri)
+	}
+
+	if err != nil {
+		return commandResult{err: fmt.Errorf("couldn't %s; %s", newCommand.name, err.Error())}
+	}
+
+	// every command responds with the upcoming tracks, so the result can be seen immediately
+	upcomingTracks, err := player.getUpcomingTracks()
+	if err != nil {
+		return commandResult{err: fmt.Errorf("couldn't get upcoming tracks; %s", err.Error())}
+	}
+
+	return commandResult{data: map[string]interface{}{
+		"contextURI": player.contextURI,
+		"tracks":     upcomingTracks,
+	}}
+}
+
+// getUpcomingTracks returns the current and all following tracks of the shuffle playlist with their names and artists
+func (player *Player) getUpcomingTracks() ([]map[string]interface{}, error) {
+	upcomingTracks := []map[string]interface{}{}
+	upcomingURIs := player.shufflePlaylistTrackURIs[min(player.currentTrackIndex, len(player.shufflePlaylistTrackURIs)):]
+
+	// the several tracks/episodes endpoints only allow 50 ids per request
+	for start := 0; start < len(upcomingURIs); start += 50 {
+		details, err := getItemDetails(upcomingURIs[start:min(start+50, len(upcomingURIs))])
+		if err != nil {
+			return upcomingTracks, fmt.Errorf("couldn't get item details; %s", err.Error())
+		}
+
+		upcomingTracks = append(upcomingTracks, details...)
+	}
+
+	return upcomingTracks, nil
+}
+
+// getItemDetails returns the uri, name and artists of the tracks/episodes in the same order as the provided URIs
+func getItemDetails(uris []string) ([]map[string]interface{}, error) {
+	var trackIDs []string
+	var episodeIDs []string
+	details := map[string]map[string]interface{}{}
+
+	for _, uri := range uris {
+		if strings.HasPrefix(uri, "spotify:episode:") {
+			episodeIDs = append(episodeIDs, strings.TrimPrefix(uri, "spotify:episode:"))
+		} else {
+			trackIDs = append(trackIDs, strings.TrimPrefix(uri, "spotify:track:"))
+		}
+	}
+
+	if len(trackIDs) > 0 {
+		tracksResponse, err := util.MakeHTTPRequest("GET", fmt.Sprintf("%s%s?ids=%s", baseURL, getTracksExtension, strings.Join(trackIDs, ",")), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't GET request several tracks; %s", err.Error())
+		}
+
+		for _, track := range tracksResponse["tracks"].([]interface{}) {
+			if track == nil {
+				continue
+			}
+
+			var artists []string
+
+			for _, artist := range track.(map[string]interface{})["artists"].([]interface{}) {
+				artists = append(artists, artist.(map[string]interface{})["name"].(string))
+			}
+
+			details[track.(map[string]interface{})["uri"].(string)] = map[string]interface{}{
+				"artists": artists,
+				"name":    track.(map[string]interface{})["name"].(string),
+			}
+		}
+	}
+
+	if len(episodeIDs) > 0 {
+		episodesResponse, err := util.MakeHTTPRequest("GET", fmt.Sprintf("%s%s?ids=%s", baseURL, getEpisodesExtension, strings.Join(episodeIDs, ",")), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't GET request several episodes; %s", err.Error())
+		}
+
+		for _, episode := range episodesResponse["episodes"].([]interface{}) {
+			if episode == nil {
+				continue
+			}
+
+			// episodes don't have artists, so we use the name of their show instead
+			details[episode.(map[string]interface{})["uri"].(string)] = map[string]interface{}{
+				"artists": []string{episode.(map[string]interface{})["show"].(map[string]interface{})["name"].(string)},
+				"name":    episode.(map[string]interface{})["name"].(string),
+			}
+		}
+	}
+
+	orderedDetails := []map[string]interface{}{}
+
+	for _, uri := range uris {
+		itemDetails, ok := details[uri]
+		if !ok {
+			itemDetails = map[string]interface{}{"artists": []string{}, "name": ""}
+		}
+
+		itemDetails["uri"] = uri
+		orderedDetails = append(orderedDetails, itemDetails)
+	}
+
+	return orderedDetails, nil
+}
+
+// reshuffle replaces all tracks after the current one with new random tracks
+func (player *Player) reshuffle() error {
+	upcomingURIs := slices.Clone(player.shufflePlaylistTrackURIs[min(player.currentTrackIndex+1, len(player.shufflePlaylistTrackURIs)):])
+
+	if len(upcomingURIs) > 0 {
+		headers := auth.UserToken.GetAccessTokenHeader()
+		headers["Content-Type"] = "application/json"
+
+		// Spotify only removes up to 100 tracks per request
+		for _, chunk := range chunkURIs(upcomingURIs, 100) {
+			bodyData := map[string]interface{}{"tracks": []map[string]string{}}
+
+			// populate body
+			for _, uri := range chunk {
+				bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
+			}
+
+			snapshotResponse, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+			if err != nil {
+				return fmt.Errorf("couldn't DELETE request upcoming tracks; %s", err.Error())
+			}
+
+			player.setSnapshotID(snapshotResponse)
+		}
+
+		player.shufflePlaylistTrackURIs = player.shufflePlaylistTrackURIs[:len(player.shufflePlaylistTrackURIs)-len(upcomingURIs)]
+
+		// the removed tracks weren't played, so they may be picked again in this cycle
+		for _, uri := range upcomingURIs {
+			delete(player.cycleTrackURIs, uri)
+		}
+
+		player.cycleFinished = false
+	}
+
+	err := player.fillShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't fill shuffle playlist; %s", err.Error())
+	}
+
+	return nil
+}
+
+// pinTrack puts a track of the context right after the current track, so it plays next
+func (player *Player) pinTrack(uri string) error {
+	if uri == "" {
+		return fmt.Errorf("no uri to pin was provided")
+	}
+
+	// moving the current track would remove it from where it's playing
+	if player.currentTrackIndex < len(player.shufflePlaylistTrackURIs) && player.shufflePlaylistTrackURIs[player.currentTrackIndex] == uri {
+		return fmt.Errorf("'%s' is already playing", uri)
+	}
+
+	isPinnable, known, err := player.isPinnable(uri)
+	if err != nil {
+		return fmt.Errorf("couldn't check if track is from context; %s", err.Error())
+	}
+
+	if !known {
+		return fmt.Errorf("the tracks of the context (%s) aren't loaded yet, because the request budget ran out; try again later", player.contextURI)
+	}
+
+	if !isPinnable {
+		return fmt.Errorf("'%s' isn't in the context (%s)", uri, player.contextURI)
+	}
+
+	headers := auth.UserToken.GetAccessTokenHeader()
+	headers["Content-Type"] = "application/json"
+
+	// if the track is already in the shuffle playlist we move it instead
+	if slices.Contains(player.shufflePlaylistTrackURIs, uri) {
+		bodyData := map[string]interface{}{"tracks": []map[string]string{{"uri": uri}}}
+
+		snapshotResponse, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+		if err != nil {
+			return fmt.Errorf("couldn't DELETE request pinned track; %s", err.Error())
+		}
+
+		player.setSnapshotID(snapshotResponse)
+
+		pinnedIndex := slices.Index(player.shufflePlaylistTrackURIs, uri)
+		player.shufflePlaylistTrackURIs = slices.Delete(player.shufflePlaylistTrackURIs, pinnedIndex, pinnedIndex+1)
+
+		if pinnedIndex < player.currentTrackIndex {
+			player.currentTrackIndex--
+		}
+	}
+
+	position := min(player.currentTrackIndex+1, len(player.shufflePlaylistTrackURIs))
+
+	bodyData := map[string]interface{}{
+		"uris":     []string{uri},
+		"position": position,
+	}
+
+	snapshotResponse, err := util.MakeHTTPRequest("POST", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+	if err != nil {
+		return fmt.Errorf("couldn't POST request add pinned track; %s", err.Error())
+	}
+
+	player.setSnapshotID(snapshotResponse)
+	player.shufflePlaylistTrackURIs = slices.Insert(player.shufflePlaylistTrackURIs, position, uri)
+	player.cycleTrackURIs[uri] = true
+
+	return nil
+}
+
+// isPinnable checks if the track could be picked for the context, it isn't known if the request budget ran out before it was found
+func (player *Player) isPinnable(uri string) (bool, bool, error) {
+	// a pool picks from its sources instead of the tracks of its trigger
+	if _, isPoolTrigger := getPoolConfig(player.contextURI); isPoolTrigger {
+		for _, source := range player.poolSources {
+			if slices.Contains(source.trackURIs, uri) {
+				return true, true, nil
+			}
+		}
+
+		return false, true, nil
+	}
+
+	return player.hasContextTrack(uri)
+}
@@ -0,0 +1,70 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"testing"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestPinTrackPlaying(t *testing.T) {
+	// the playing track gets rejected before any request, so the player needs no shuffle playlist
+	player := Player{
+		contextURI:               "spotify:playlist:context",
+		currentTrackIndex:        1,
+		shufflePlaylistTrackURIs: []string{"spotify:track:a", "spotify:track:b", "spotify:track:c"},
+		poolSources:              []poolSource{{trackURIs: []string{"spotify:track:a", "spotify:track:b", "spotify:track:c"}}},
+	}
+
+	if err := player.pinTrack("spotify:track:b"); err == nil {
+		t.Error("got no error for pinning the playing track")
+	}
+
+	if len(player.shufflePlaylistTrackURIs) != 3 || player.shufflePlaylistTrackURIs[1] != "spotify:track:b" || player.currentTrackIndex != 1 {
+		t.Errorf("got %v at index %d, expected the shuffle playlist to stay the same", player.shufflePlaylistTrackURIs, player.currentTrackIndex)
+	}
+}
+
+func TestIsPinnable(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	util.AppConfig = util.Config{Pools: []util.PoolConfig{{Name: "pool", Trigger: "spotify:playlist:trigger"}}}
+
+	poolSources := []poolSource{
+		{uri: "spotify:artist:source", trackURIs: []string{"spotify:track:a"}},
+		{uri: "spotify:playlist:source", trackURIs: []string{"spotify:track:b"}},
+	}
+	contextSources := []poolSource{{uri: "spotify:playlist:context", trackURIs: []string{"spotify:track:a"}}}
+
+	tests := []struct {
+		name        string
+		contextURI  string
+		poolSources []poolSource
+		uri         string
+		expected    bool
+	}{
+		{name: "in the second pool source", contextURI: "spotify:playlist:trigger", poolSources: poolSources, uri: "spotify:track:b", expected: true},
+		{name: "not in the pool sources", contextURI: "spotify:playlist:trigger", poolSources: poolSources, uri: "spotify:track:c", expected: false},
+		{name: "in the context without a pool", contextURI: "spotify:playlist:context", poolSources: contextSources, uri: "spotify:track:a", expected: true},
+		{name: "not in the context without a pool", contextURI: "spotify:playlist:context", poolSources: contextSources, uri: "spotify:track:b", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := Player{contextURI: test.contextURI, poolSources: test.poolSources}
+
+			isPinnable, known, err := player.isPinnable(test.uri)
+			if err != nil {
+				t.Fatalf("isPinnable returned an error: %s", err.Error())
+			}
+
+			if !known || isPinnable != test.expected {
+				t.Errorf("got %t (known %t), expected %t", isPinnable, known, test.expected)
+			}
+		})
+	}
+}
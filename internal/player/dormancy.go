@@ -0,0 +1,79 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/schedule"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// dormantRecheckTime is the longest we stay dormant without checking the schedule again, so a reloaded schedule gets noticed
+const dormantRecheckTime = time.Minute
+
+// <---------------------------------------------------------------------------------------------------->
+
+// checkSchedule returns how long to stay dormant, if we're outside of the schedule's windows. If a window just closed, the playback gets handed back first.
+func (player *Player) checkSchedule() (time.Duration, error) {
+	// the schedule was validated with the config, so it always parses
+	activeSchedule, _ := schedule.New(util.AppConfig.Schedule)
+	currentTime := time.Now()
+
+	if activeSchedule.IsActive(currentTime) {
+		return 0, nil
+	}
+
+	nextStart := activeSchedule.NextStart(currentTime)
+
+	if player.state != stateDormant {
+		err := player.handBack()
+		if err != nil {
+			return 0, fmt.Errorf("couldn't hand back playback; %s", err.Error())
+		}
+
+		// the context might have been reset, which has to be saved before we stop polling
+		err = player.saveSession()
+		if err != nil {
+			return 0, fmt.Errorf("couldn't save session; %s", err.Error())
+		}
+
+		err = player.transition(stateDormant, fmt.Sprintf("outside of the schedule's windows until %s", nextStart.Format(time.DateTime)))
+		if err != nil {
+			return 0, fmt.Errorf("couldn't transition state; %s", err.Error())
+		}
+	}
+
+	return max(min(nextStart.Sub(currentTime), dormantRecheckTime), time.Second), nil
+}
+
+// handBack moves a playing shuffle playlist back onto its original context with Spotify's shuffle, so the listening continues without us
+func (player *Player) handBack() error {
+	if player.contextURI == "" {
+		return nil
+	}
+
+	playbackResponse, err := util.MakeHTTPRequest("GET", baseURL+playbackStateExtension+"?additional_types=episode", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't GET request playback state; %s", err.Error())
+	}
+
+	// starting the original context would also resume a paused playback
+	if len(playbackResponse) == 0 || !playbackResponse["is_playing"].(bool) || playbackResponse["item"] == nil {
+		return nil
+	}
+
+	context, _ := playbackResponse["context"].(map[string]interface{})
+	if context == nil || context["uri"].(string) != player.shufflePlaylistURI {
+		return nil
+	}
+
+	player.setCheckValues(&playbackResponse)
+
+	return player.returnToContext(playbackResponse["item"].(map[string]interface{})["uri"].(string), int(playbackResponse["progress_ms"].(float64)), true)
+}
@@ -0,0 +1,51 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"log/slog"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/events"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/history"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// recordHistory appends a track that was completed, skipped or moved back from in the shuffle playlist to the history
+func (player *Player) recordHistory(event events.Event) {
+	outcome := history.OutcomeCompleted
+
+	switch event.Type {
+	case events.TrackCompleted:
+	// pressing previous also leaves the track before its end
+	case events.TrackSkipped, events.MovedBack:
+		outcome = history.OutcomeSkipped
+	default:
+		return
+	}
+
+	// tracks outside of the shuffle playlist weren't picked by us
+	if player.shufflePlaylistURI == "" || event.Previous.ContextURI != player.shufflePlaylistURI {
+		return
+	}
+
+	record := history.Record{
+		Timestamp:  event.Current.Timestamp,
+		TrackURI:   event.Previous.ItemURI,
+		ArtistURIs: event.Previous.ArtistURIs,
+		AlbumURI:   event.Previous.AlbumURI,
+		ContextURI: player.contextURI,
+		Device:     event.Previous.DeviceName,
+		Outcome:    outcome,
+		ProgressMS: event.Previous.ProgressMS,
+		DurationMS: event.Previous.DurationMS,
+	}
+
+	// a missing record isn't worth stopping the playback for
+	err := history.Append(util.AppConfig.HistoryPath, record)
+	if err != nil {
+		slog.Warn("couldn't record track in history", "track", record.TrackURI, "context", record.ContextURI, "error", err)
+	}
+}
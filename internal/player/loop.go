@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/events"
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
 )
 
@@ -15,13 +16,31 @@ import (
 
 const (
 	baseURL                        = "https://api.spotify.com/v1/"
+	getAlbumsExtension             = "albums"
+	getEpisodesExtension           = "episodes"
 	getPlaylistExtension           = "playlists/"
+	getTracksExtension             = "tracks"
 	playbackStateExtension         = "me/player"
+	queueExtension                 = "me/player/queue"
+	savedTracksExtension           = "me/tracks"
 	startPlaybackExtension         = "me/player/play"
 	tooglePlaybackShuffleExtension = "me/player/shuffle"
+	userPlaylistsExtension         = "me/playlists"
 	userProfileExtension           = "me"
 )
 
+const (
+	// eventBufferSize is how many playback events a subscriber can hold, before further events get dropped for it
+	eventBufferSize = 32
+	// maxRandomTrackMisses is how often we request random tracks from a context, before we look through the whole context
+	maxRandomTrackMisses = 10
+	// pollGapMargin is how much longer than the loop refresh time two polls may be apart, before we assume polls were missed,
+	// the request budget alone can delay a poll by up to a minute
+	pollGapMargin = 2 * time.Minute
+	// shufflePlaylistMarker is part of the shuffle playlist's description, so we can find it without its JSON
+	shufflePlaylistMarker = "automatically created by SpotifyTrueRandomShuffle"
+)
+
 // <---------------------------------------------------------------------------------------------------->
 
 // Start is our main loop which repeats infinitely and provides with all parts needed for TrueRandomShuffle
@@ -38,48 +57,48 @@ func Start() error {
 		return fmt.Errorf("couldn't get shuffle playlist; %s", err.Error())
 	}
 
+	waitTime := secondsToDuration(util.AppConfig.LoopRefreshTime)
+
 	// the main program loop starts here
 	for {
-		// slow down the loop so we don't get rate limited
-		time.Sleep(time.Duration(int64(util.AppConfig.LoopRefreshTime * float64(time.Second))))
-
-		// get the playback state for tests and context
-		playbackResponse, err := util.MakeHTTPRequest("GET", baseURL+playbackStateExtension, auth.UserToken.GetAccessTokenHeader(), nil, nil)
-		if err != nil {
-			return fmt.Errorf("couldn't GET request playback state; %s", err.Error())
-		}
+		// slow down the loop so we don't get rate limited, commands from the local API and reloaded configs get handled meanwhile
+		userPlayer.serveCommands(waitTime)
 
-		// run general checks
-		passed, err := userPlayer.runChecks(&playbackResponse)
+		// outside of the schedule's windows we don't poll the playback at all
+		dormantTime, err := userPlayer.checkSchedule()
 		if err != nil {
-			return fmt.Errorf("couldn't run all checks; %s", err.Error())
+			return fmt.Errorf("couldn't check schedule; %s", err.Error())
 		}
 
-		if !passed {
+		if dormantTime > 0 {
+			waitTime = dormantTime
 			continue
 		}
 
-		// remove tracks behind the currently playing one
-		err = userPlayer.removeFinishedTracks(playbackResponse["item"].(map[string]interface{})["uri"].(string))
+		// get the playback state for tests and context
+		playbackResponse, err := util.MakeHTTPRequest("GET", baseURL+playbackStateExtension+"?additional_types=episode", auth.UserToken.GetAccessTokenHeader(), nil, nil)
 		if err != nil {
-			return fmt.Errorf("couldn't remove finished tracks; %s", err.Error())
+			return fmt.Errorf("couldn't GET request playback state; %s", err.Error())
 		}
 
-		// fill shuffle playlist up until it has shufflePlaylistLength tracks
-		err = userPlayer.fillShufflePlaylist()
-		if err != nil {
-			return fmt.Errorf("couldn't fill shuffle playlist; %s", err.Error())
-		}
+		// derive the events from the new playback state and handle our own
+		pollTime := time.Now()
+		userPlayer.events.Update(events.NewState(playbackResponse, pollTime))
+		userPlayer.handleEvents(true)
 
-		// check if we're listening to the temp playlist right now
-		if playbackResponse["context"].(map[string]interface{})["uri"].(string) == userPlayer.shufflePlaylistURI {
-			continue
+		// move the player into the state for the playback and execute it
+		err = userPlayer.update(&playbackResponse, pollTime)
+		if err != nil {
+			return fmt.Errorf("couldn't update player; %s", err.Error())
 		}
 
-		// start playing our palylist
-		err = userPlayer.startShufflePlaylist()
+		// save the session, so it can be continued after a restart
+		err = userPlayer.saveSession()
 		if err != nil {
-			return fmt.Errorf("couldn't start playing temp playlist; %s", err.Error())
+			return fmt.Errorf("couldn't save session; %s", err.Error())
 		}
+
+		// adapt the wait time to the playback
+		waitTime = userPlayer.polls.next(playbackResponse)
 	}
 }
@@ -0,0 +1,117 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Reset clears and unfollows the shuffle playlist and resets the shuffle playlist and session JSONs
+func Reset() error {
+	player, err := newPlayer()
+	if err != nil {
+		return fmt.Errorf("couldn't create a new player; %s", err.Error())
+	}
+
+	_, err = player.loadShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't load shuffle playlist; %s", err.Error())
+	}
+
+	if player.shufflePlaylistURI != "" {
+		// a playlist of another user or one that doesn't exist anymore isn't ours to clear
+		valid, err := player.verifyShufflePlaylist()
+		if err != nil {
+			return fmt.Errorf("couldn't verify shuffle playlist; %s", err.Error())
+		}
+
+		if valid {
+			err = player.clearShufflePlaylist()
+			if err != nil {
+				return fmt.Errorf("couldn't clear shuffle playlist; %s", err.Error())
+			}
+
+			// Spotify can't delete playlists, unfollowing it is the same as deleting it in the app
+			err = player.hideShufflePlaylist(false)
+			if err != nil {
+				return fmt.Errorf("couldn't unfollow shuffle playlist; %s", err.Error())
+			}
+		}
+	}
+
+	err = util.WriteJSONData(util.AppConfig.ShufflePlaylistPath, map[string]interface{}{"href": "", "uri": ""}, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't reset shuffle playlist JSON; %s", err.Error())
+	}
+
+	err = util.WriteJSONData(util.AppConfig.SessionPath, (&Player{}).getSession(), 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't reset session JSON; %s", err.Error())
+	}
+
+	return nil
+}
+
+// CheckShufflePlaylist checks without changing anything, that the shuffle playlist from the JSON exists and belongs to the user
+func CheckShufflePlaylist() (string, error) {
+	player, err := newPlayer()
+	if err != nil {
+		return "", fmt.Errorf("couldn't create a new player; %s", err.Error())
+	}
+
+	_, err = player.loadShufflePlaylist()
+	if err != nil {
+		return "", fmt.Errorf("couldn't load shuffle playlist; %s", err.Error())
+	}
+
+	if player.shufflePlaylistURI == "" {
+		return "", fmt.Errorf("there is no shuffle playlist yet, it gets created on the next run")
+	}
+
+	playlistResponse, err := util.MakeHTTPRequest("GET", player.shufflePlaylistHREF+"?fields=owner(id)", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return player.shufflePlaylistURI, fmt.Errorf("couldn't GET request shuffle playlist; %s", err.Error())
+	}
+
+	if playlistResponse["owner"].(map[string]interface{})["id"].(string) != player.userID {
+		return player.shufflePlaylistURI, fmt.Errorf("the shuffle playlist belongs to another user, it gets replaced on the next run")
+	}
+
+	return player.shufflePlaylistURI, nil
+}
+
+// loadShufflePlaylist sets the href and uri of the shuffle playlist from its JSON or if that was lost from the session, without finding
+// or creating it. It returns if the playlist came from the session, so its JSON has to be written again.
+func (player *Player) loadShufflePlaylist() (bool, error) {
+	if util.FileExists(util.AppConfig.ShufflePlaylistPath) {
+		jsonData, err := util.GetJSONData(util.AppConfig.ShufflePlaylistPath)
+		if err != nil {
+			return false, fmt.Errorf("couldn't get shuffle playlist JSON; %s", err.Error())
+		}
+
+		player.shufflePlaylistHREF, _ = jsonData["href"].(string)
+		player.shufflePlaylistURI, _ = jsonData["uri"].(string)
+	}
+
+	if player.shufflePlaylistURI != "" || !util.FileExists(util.AppConfig.SessionPath) {
+		return false, nil
+	}
+
+	// Spotify doesn't list the playlist once it's hidden, so the session is the only other place that still knows it
+	sessionMap, err := util.GetJSONData(util.AppConfig.SessionPath)
+	if err != nil {
+		// resuming the session reports that it can't be read
+		return false, nil
+	}
+
+	player.shufflePlaylistHREF, _ = sessionMap["shufflePlaylistHREF"].(string)
+	player.shufflePlaylistURI, _ = sessionMap["shufflePlaylistURI"].(string)
+
+	return player.shufflePlaylistURI != "", nil
+}
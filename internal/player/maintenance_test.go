@@ -0,0 +1,86 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestLoadShufflePlaylist(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	tests := []struct {
+		name string
+		// an empty JSON or session means the file doesn't exist
+		shufflePlaylistJSON string
+		session             string
+		expectedURI         string
+		expectedFromSession bool
+	}{
+		{
+			name:                "from its JSON",
+			shufflePlaylistJSON: `{"href": "https://api.spotify.com/v1/playlists/shuffle", "uri": "spotify:playlist:shuffle"}`,
+			session:             `{"shufflePlaylistHREF": "https://api.spotify.com/v1/playlists/old", "shufflePlaylistURI": "spotify:playlist:old"}`,
+			expectedURI:         "spotify:playlist:shuffle",
+		},
+		{
+			name:                "JSON was lost",
+			session:             `{"shufflePlaylistHREF": "https://api.spotify.com/v1/playlists/shuffle", "shufflePlaylistURI": "spotify:playlist:shuffle"}`,
+			expectedURI:         "spotify:playlist:shuffle",
+			expectedFromSession: true,
+		},
+		{
+			name:                "after a reset",
+			shufflePlaylistJSON: `{"href": "", "uri": ""}`,
+			session:             `{"shufflePlaylistHREF": "", "shufflePlaylistURI": ""}`,
+		},
+		{
+			name:    "session from before it knew the playlist",
+			session: `{"contextURI": "spotify:album:context"}`,
+		},
+		{
+			name:    "unreadable session",
+			session: `{"shufflePlaylistURI": "spotify:pla`,
+		},
+		{
+			name: "nothing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stateDir := t.TempDir()
+			util.AppConfig.ShufflePlaylistPath = filepath.Join(stateDir, "shufflePlaylist.json")
+			util.AppConfig.SessionPath = filepath.Join(stateDir, "session.json")
+
+			for path, content := range map[string]string{util.AppConfig.ShufflePlaylistPath: test.shufflePlaylistJSON, util.AppConfig.SessionPath: test.session} {
+				if content == "" {
+					continue
+				}
+
+				err := os.WriteFile(path, []byte(content), 0644)
+				if err != nil {
+					t.Fatalf("couldn't write %s: %s", path, err.Error())
+				}
+			}
+
+			player := Player{}
+
+			fromSession, err := player.loadShufflePlaylist()
+			if err != nil {
+				t.Fatalf("loadShufflePlaylist returned an error: %s", err.Error())
+			}
+
+			if player.shufflePlaylistURI != test.expectedURI || fromSession != test.expectedFromSession {
+				t.Errorf("got %q (from session %t), expected %q (from session %t)", player.shufflePlaylistURI, fromSession, test.expectedURI, test.expectedFromSession)
+			}
+		})
+	}
+}
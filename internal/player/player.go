@@ -2,10 +2,18 @@
 package player
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/events"
 	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
 )
 
@@ -17,8 +25,21 @@ type Player struct {
 	userCountry string
 	userID      string
 
+	// the current state, which only changes through transitions
+	state playerState
+
+	// the events derived from the playback states and our subscription to them
+	events       *events.Stream
+	playerEvents <-chan events.Event
+
+	// decides when the next poll happens and keeps track of the request budget
+	polls pollSchedule
+
 	// check values
 	currentlyPlayingType string
+	deviceID             string
+	deviceName           string
+	deviceType           string
 	isPlaying            bool
 	isPrivateSession     bool
 	repeatState          string
@@ -30,18 +51,46 @@ type Player struct {
 	contextType string
 	contextURI  string
 
-	contextLength int
+	contextLength    int
+	contextTrackURIs map[string]bool
+	// contextTracksURL is the next page of the context's own tracks, that wasn't loaded into contextTrackURIs yet
+	contextTracksURL string
+	poolSources      []poolSource
+
+	// all tracks that have been added to the shuffle playlist, since the current cycle through the context started
+	cycleTrackURIs map[string]bool
+	// cycleFinished is set once every track of the context was used without repeat, playing the context again starts a new cycle
+	cycleFinished bool
+
+	// the tracks the user queued, that we wait for before we prepare the shuffle playlist
+	userQueuedURIs []string
+
+	// the decisions of the context rules for all contexts that were checked
+	contextRuleDecisions map[string]ruleDecision
 
 	// shuffle playlist values
-	shufflePlaylistHREF      string
-	shufflePlaylistLength    int
-	shufflePlaylistTrackURIs []string
-	shufflePlaylistURI       string
+	currentTrackIndex         int
+	shufflePlaylistHREF       string
+	shufflePlaylistLength     int
+	shufflePlaylistSnapshotID string
+	shufflePlaylistTrackURIs  []string
+	shufflePlaylistURI        string
+
+	// the last session we saved, so we only write it on changes
+	savedSession map[string]interface{}
 }
 
 // newPlayer creates and returns a player with the userID and userCountry set
 func newPlayer() (*Player, error) {
-	player := Player{}
+	player := Player{
+		contextRuleDecisions: map[string]ruleDecision{},
+		cycleTrackURIs:       map[string]bool{},
+		events:               events.NewStream(secondsToDuration(util.AppConfig.LoopRefreshTime) + pollGapMargin),
+	}
+	player.playerEvents = player.events.Subscribe(eventBufferSize)
+
+	// the request budget counts from the start of the player
+	player.polls.sample(time.Now())
 
 	// Get the user's profile for their country
 	userProfile, err := util.MakeHTTPRequest("GET", baseURL+userProfileExtension, auth.UserToken.GetAccessTokenHeader(), nil, nil)
@@ -56,36 +105,167 @@ func newPlayer() (*Player, error) {
 	return &player, nil
 }
 
-// getShufflePlaylist gets the href and uri of the shuffle playlist. If it needs to it will also create the playlist.
+// setShufflePlaylist gets the href and uri of the shuffle playlist. If it needs to it will also find or create the playlist.
 func (player *Player) setShufflePlaylist() error {
-	// get the shuffle playlist values from the JSON
-	shufflePlaylistMap, err := util.GetJSONData(util.AppConfig.ShufflePlaylistPath)
+	fromSession, err := player.loadShufflePlaylist()
 	if err != nil {
-		return fmt.Errorf("couldn't get temp playlist json; %s", err.Error())
+		return fmt.Errorf("couldn't load shuffle playlist; %s", err.Error())
 	}
 
-	// did we get temp a playlist from the json?
-	if shufflePlaylistMap["uri"].(string) != "" {
-		player.shufflePlaylistHREF = shufflePlaylistMap["href"].(string)
-		player.shufflePlaylistURI = shufflePlaylistMap["uri"].(string)
-
-		// reset the temp playlist from a previous execution to avoid missmatching with our data
-		err = player.clearShufflePlaylist()
+	// did we get a shuffle playlist from the JSON or the session?
+	if player.shufflePlaylistURI != "" {
+		// the playlist might have been deleted or belong to another user
+		valid, err := player.verifyShufflePlaylist()
 		if err != nil {
-			return fmt.Errorf("couldn't clear shuffle playlist; %s", err.Error())
+			return fmt.Errorf("couldn't verify shuffle playlist; %s", err.Error())
 		}
 
-		return nil
+		if valid {
+			// the JSON was lost, so it gets written again for the next start
+			if fromSession {
+				err = player.saveShufflePlaylist()
+				if err != nil {
+					return fmt.Errorf("couldn't save shuffle playlist; %s", err.Error())
+				}
+			}
+
+			return player.continueShufflePlaylist()
+		}
 	}
 
-	href, uri, err := player.createShufflePlaylist()
+	// look for a shuffle playlist from a previous execution that the user followed again, before we create a new one
+	href, uri, err := player.findShufflePlaylist()
 	if err != nil {
-		return fmt.Errorf("couldn't create shuffle playlist; %s", err.Error())
+		return fmt.Errorf("couldn't find shuffle playlist; %s", err.Error())
+	}
+
+	if uri == "" {
+		href, uri, err = player.createShufflePlaylist()
+		if err != nil {
+			return fmt.Errorf("couldn't create shuffle playlist; %s", err.Error())
+		}
 	}
 
 	player.shufflePlaylistHREF = href
 	player.shufflePlaylistURI = uri
 
+	err = player.saveShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't save shuffle playlist; %s", err.Error())
+	}
+
+	// a found playlist has to be reset, we don't know its tracks
+	err = player.clearShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't clear shuffle playlist; %s", err.Error())
+	}
+
+	return player.hideShufflePlaylist(false)
+}
+
+// saveShufflePlaylist writes the href and uri of the shuffle playlist to its JSON for use after a restart
+func (player *Player) saveShufflePlaylist() error {
+	err := util.WriteJSONData(
+		util.AppConfig.ShufflePlaylistPath,
+		map[string]interface{}{
+			"href": player.shufflePlaylistHREF,
+			"uri":  player.shufflePlaylistURI,
+		},
+		0644,
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't write shuffle playlist data to JSON; %s", err.Error())
+	}
+
+	return nil
+}
+
+// continueShufflePlaylist continues the session from a previous execution or resets the shuffle playlist, if it doesn't match
+func (player *Player) continueShufflePlaylist() error {
+	resumed, err := player.resumeSession()
+	if err != nil {
+		return fmt.Errorf("couldn't resume session; %s", err.Error())
+	}
+
+	if resumed {
+		return nil
+	}
+
+	// otherwise reset the temp playlist to avoid missmatching with our data
+	err = player.clearShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't clear shuffle playlist; %s", err.Error())
+	}
+
+	return nil
+}
+
+// verifyShufflePlaylist checks that the shuffle playlist still exists and belongs to the user, it also keeps it private and unfollowed
+func (player *Player) verifyShufflePlaylist() (bool, error) {
+	playlistResponse, err := util.MakeHTTPRequest("GET", player.shufflePlaylistHREF+"?fields=owner(id),public", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		var apiErr *util.APIError
+
+		// the playlist doesn't exist anymore
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("couldn't GET request shuffle playlist; %s", err.Error())
+	}
+
+	// the playlist might be from another account that was used before
+	if playlistResponse["owner"].(map[string]interface{})["id"].(string) != player.userID {
+		return false, nil
+	}
+
+	isPublic, _ := playlistResponse["public"].(bool)
+
+	err = player.hideShufflePlaylist(isPublic)
+	if err != nil {
+		return false, fmt.Errorf("couldn't hide shuffle playlist; %s", err.Error())
+	}
+
+	return true, nil
+}
+
+// findShufflePlaylist looks for a shuffle playlist of the user by its description and returns its href and uri, if it found one.
+// Spotify only lists playlists the user follows, while we unfollow the shuffle playlist to hide it. So it's only found, if the user followed it again.
+func (player *Player) findShufflePlaylist() (string, string, error) {
+	playlists, err := getAllPages(fmt.Sprintf("%s%s?limit=%d", baseURL, userPlaylistsExtension, 50))
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't get all user playlists; %s", err.Error())
+	}
+
+	for _, playlist := range playlists {
+		description, _ := playlist["description"].(string)
+
+		if playlist["owner"].(map[string]interface{})["id"].(string) == player.userID && strings.Contains(description, shufflePlaylistMarker) {
+			return playlist["href"].(string), playlist["uri"].(string), nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// hideShufflePlaylist makes sure the shuffle playlist is private and not in the user's library
+func (player *Player) hideShufflePlaylist(isPublic bool) error {
+	if isPublic {
+		headers := auth.UserToken.GetAccessTokenHeader()
+		headers["Content-Type"] = "application/json"
+
+		_, err := util.MakeHTTPRequest("PUT", player.shufflePlaylistHREF, headers, nil, map[string]interface{}{"public": false})
+		if err != nil {
+			return fmt.Errorf("couldn't PUT request make shuffle playlist private; %s", err.Error())
+		}
+	}
+
+	// removing the playlist from the library also works, if it isn't in there
+	_, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/followers", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't DELETE request unfollow shuffle playlist; %s", err.Error())
+	}
+
 	return nil
 }
 
@@ -113,17 +293,22 @@ func (player *Player) clearShufflePlaylist() error {
 	headers := auth.UserToken.GetAccessTokenHeader()
 	headers["Content-Type"] = "application/json"
 
-	bodyData := map[string]interface{}{"tracks": []map[string]string{}}
+	// Spotify only removes up to 100 tracks per request
+	for _, chunk := range chunkURIs(trackURIs, 100) {
+		bodyData := map[string]interface{}{"tracks": []map[string]string{}}
 
-	// populate body
-	for _, uri := range trackURIs {
-		bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
-	}
+		// populate body
+		for _, uri := range chunk {
+			bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
+		}
 
-	// make call to remove all tracks of the shuffle playlist
-	_, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
-	if err != nil {
-		return fmt.Errorf("couldn't DELETE request all tracks from the temp playlist; %s", err.Error())
+		// make call to remove the tracks of the shuffle playlist
+		snapshotResponse, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+		if err != nil {
+			return fmt.Errorf("couldn't DELETE request all tracks from the temp playlist; %s", err.Error())
+		}
+
+		player.setSnapshotID(snapshotResponse)
 	}
 
 	return nil
@@ -134,20 +319,36 @@ func (player *Player) getShufflePlaylistTrackURIs() ([]string, error) {
 	// define the storage var early
 	var trackURIs []string
 
-	// get current shuffle playlist tracks
-	tracksResponse, err := util.MakeHTTPRequest("GET", player.shufflePlaylistHREF+"/tracks", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	// get current shuffle playlist tracks, with the adopt policy there can be more than a page of them
+	items, err := getAllPages(fmt.Sprintf("%s/tracks?limit=%d", player.shufflePlaylistHREF, 100))
 	if err != nil {
-		return trackURIs, fmt.Errorf("couldn't GET request random track from context; %s", err.Error())
+		return trackURIs, fmt.Errorf("couldn't get all shuffle playlist tracks; %s", err.Error())
 	}
 
 	// append the URIs to trackURIs
-	for _, item := range tracksResponse["items"].([]interface{}) {
-		trackURIs = append(trackURIs, item.(map[string]interface{})["track"].(map[string]interface{})["uri"].(string))
+	for _, item := range items {
+		// tracks that aren't available anymore can be null
+		track, ok := item["track"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		trackURIs = append(trackURIs, track["uri"].(string))
 	}
 
 	return trackURIs, nil
 }
 
+// getShufflePlaylistSnapshotID makes a call to Spotify and returns the current snapshot id of the shuffle playlist
+func (player *Player) getShufflePlaylistSnapshotID() (string, error) {
+	snapshotResponse, err := util.MakeHTTPRequest("GET", player.shufflePlaylistHREF+"?fields=snapshot_id", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("couldn't GET request shuffle playlist snapshot id; %s", err.Error())
+	}
+
+	return snapshotResponse["snapshot_id"].(string), nil
+}
+
 // createShufflePlaylist makes the shuffle playlist on spotify and returns it's href and uri
 func (player *Player) createShufflePlaylist() (string, string, error) {
 	// declare vars for storage early
@@ -159,7 +360,7 @@ func (player *Player) createShufflePlaylist() (string, string, error) {
 
 	bodyData := map[string]interface{}{
 		"name":        "TrueRandomShuffle",
-		"description": "DON'T CHANGE ANYTHING in this playlist. This playlist was automatically created by SpotifyTrueRandomShuffle. You may remove it from your library.",
+		"description": "DON'T CHANGE ANYTHING in this playlist. This playlist was " + shufflePlaylistMarker + ". You may remove it from your library.",
 		"public":      false,
 	}
 
@@ -171,69 +372,18 @@ func (player *Player) createShufflePlaylist() (string, string, error) {
 	// set the response values
 	shufflePlaylisthref = createPlaylistResponse["href"].(string)
 	shufflePlaylisturi = createPlaylistResponse["uri"].(string)
-
-	// immeaditly remove the shuffle playlist from the user's library
-	_, err = util.MakeHTTPRequest("DELETE", shufflePlaylisthref+"/followers", auth.UserToken.GetAccessTokenHeader(), nil, nil)
-	if err != nil {
-		return shufflePlaylisthref, shufflePlaylisturi, fmt.Errorf("couldn't POST request create temp playlist; %s", err.Error())
-	}
-
-	// write to the shuffle playlist JSON for use after a restart
-	err = util.WriteJSONData(
-		util.AppConfig.ShufflePlaylistPath,
-		map[string]interface{}{
-			"href": shufflePlaylisthref,
-			"uri":  shufflePlaylisturi,
-		},
-	)
-	if err != nil {
-		return shufflePlaylisthref, shufflePlaylisturi, fmt.Errorf("couldn't write shuffle playlist data to JSON; %s", err.Error())
-	}
+	player.setSnapshotID(createPlaylistResponse)
 
 	return shufflePlaylisthref, shufflePlaylisturi, nil
 }
 
-// runChecks ensures that all check and context values are up to date and valid || false is an error
-func (player *Player) runChecks(playbackResponse *map[string]interface{}) (bool, error) {
-	// check if there is a playback state
-	if len(*playbackResponse) == 0 {
-		return false, nil
-	}
-
-	// set the default check values
-	player.setCheckValues(playbackResponse)
-
-	// run the default checks
-	if !player.playbackChecks() {
-		return false, nil
-	}
-
-	// check if a context exists (i.e. if the user is listening to a song outside of an album/playlist)
-	if (*playbackResponse)["context"] == nil {
-		return false, nil
-	}
-
-	err := player.updateContext(playbackResponse)
-	if err != nil {
-		return false, fmt.Errorf("couldn't update context; %s", err.Error())
-	}
-
-	// if thex context only has 1 track fail
-	if player.contextLength == 1 {
-		return false, nil
-	}
-
-	if !player.shuffleCheck((*playbackResponse)["context"].(map[string]interface{})["uri"].(string)) {
-		return false, nil
-	}
-
-	return true, nil
-}
-
 // loadPlaybackOnPlayer receives the response from the API call for playback State and loads it onto the player
 func (player *Player) setCheckValues(playbackResponse *map[string]interface{}) {
 	// add all relevant values to the player
 	player.currentlyPlayingType = (*playbackResponse)["currently_playing_type"].(string)
+	player.deviceID, _ = (*playbackResponse)["device"].(map[string]interface{})["id"].(string) // the id can be null for restricted devices
+	player.deviceName = (*playbackResponse)["device"].(map[string]interface{})["name"].(string)
+	player.deviceType = (*playbackResponse)["device"].(map[string]interface{})["type"].(string)
 	player.isPlaying = (*playbackResponse)["is_playing"].(bool)
 	player.isPrivateSession = (*playbackResponse)["device"].(map[string]interface{})["is_private_session"].(bool)
 	player.repeatState = (*playbackResponse)["repeat_state"].(string)
@@ -241,34 +391,35 @@ func (player *Player) setCheckValues(playbackResponse *map[string]interface{}) {
 	player.smartShuffle = (*playbackResponse)["smart_shuffle"].(bool)
 }
 
-// playbackChecks makes sure all remaining factors pass || false is an error
-func (player *Player) playbackChecks() bool {
-	// the playback response has to pass all of these checks
-	return player.isPlaying && // is the user playing something right now
-		!player.isPrivateSession && // is in a private session
-		player.currentlyPlayingType == "track" && // is listening to a track
-		player.repeatState != "track" && // doesn't have repeat on a track turned on
-		player.contextType != "show" && // the context can't be a show
-		player.contextType != "artist" // the context can't be an artist
-}
+// isDeviceAllowed checks if the current device is in the configured devices, if no devices are configured all are allowed
+func (player *Player) isDeviceAllowed() bool {
+	if len(util.AppConfig.Devices) == 0 {
+		return true
+	}
 
-// updateContext ensures the context on the player is always up to date, by potentially reseting and updating the context and the shuffle playlist
-func (player *Player) updateContext(playbackResponse *map[string]interface{}) error {
-	err := player.clearContext((*playbackResponse)["context"].(map[string]interface{})["uri"].(string))
-	if err != nil {
-		return fmt.Errorf("couldn't clear context on player and reset shuffle playlist; %s", err.Error())
+	for _, device := range util.AppConfig.Devices {
+		if device == player.deviceID ||
+			strings.EqualFold(device, player.deviceName) ||
+			strings.EqualFold(device, player.deviceType) {
+			return true
+		}
 	}
-	// if the context isn't empty it's up to date
-	if player.contextURI != "" {
-		return nil
+
+	return false
+}
+
+// withDevice adds the current device to a player endpoint, so the command can't affect another device the user switched to
+func (player *Player) withDevice(endpointURL string) string {
+	if player.deviceID == "" {
+		return endpointURL
 	}
 
-	err = player.setContext(playbackResponse)
-	if err != nil {
-		return fmt.Errorf("couldn't set context; %s", err.Error())
+	separator := "?"
+	if strings.Contains(endpointURL, "?") {
+		separator = "&"
 	}
 
-	return nil
+	return endpointURL + separator + "device_id=" + url.QueryEscape(player.deviceID)
 }
 
 // clearContext returns a bool on if it cleared the context and emptied the shuffle playlist
@@ -278,12 +429,22 @@ func (player *Player) clearContext(newContextURI string) error {
 		return nil
 	}
 
+	// playing the context again after its cycle finished starts a new one, while preparing it the context is still being played
+	if newContextURI == player.contextURI && player.cycleFinished && player.state != statePreparing {
+		return player.resetContext()
+	}
+
 	// check that the user is playing a context that isn't the shuffle playlist or the original context
 	if newContextURI == player.shufflePlaylistURI ||
 		newContextURI == player.contextURI {
 		return nil
 	}
 
+	return player.resetContext()
+}
+
+// resetContext empties the shuffle playlist and removes all context values from the player
+func (player *Player) resetContext() error {
 	err := player.clearShufflePlaylist()
 	if err != nil {
 		return fmt.Errorf("couldn't clear shuffle playlist; %s", err.Error())
@@ -294,6 +455,13 @@ func (player *Player) clearContext(newContextURI string) error {
 	player.contextType = ""
 	player.contextURI = ""
 	player.contextLength = 0
+	player.contextTrackURIs = nil
+	player.contextTracksURL = ""
+	player.poolSources = nil
+	player.cycleTrackURIs = map[string]bool{}
+	player.cycleFinished = false
+	player.userQueuedURIs = nil
+	player.currentTrackIndex = 0
 	player.shufflePlaylistTrackURIs = nil
 
 	return nil
@@ -311,11 +479,68 @@ func (player *Player) setContext(playbackResponse *map[string]interface{}) error
 	player.contextType = (*playbackResponse)["context"].(map[string]interface{})["type"].(string)
 	player.contextURI = (*playbackResponse)["context"].(map[string]interface{})["uri"].(string)
 
-	length, err := player.getContextLength()
-	if err != nil {
-		return fmt.Errorf("couldn't get context length; %s", err.Error())
+	// Liked Songs don't have a context endpoint of their own, so we use the user's saved tracks instead
+	if player.contextType == "collection" {
+		player.contextHREF = baseURL + savedTracksExtension
+	}
+
+	return player.loadContext()
+}
+
+// loadContext gets the length and if needed the pool of the context on the player
+func (player *Player) loadContext() error {
+	pool, isPoolTrigger := getPoolConfig(player.contextURI)
+
+	switch {
+	// a configured pool replaces the tracks of the context that triggers it
+	case isPoolTrigger:
+		length, err := player.buildPool(pool)
+		if err != nil {
+			return fmt.Errorf("couldn't build pool (%s); %s", pool.Name, err.Error())
+		}
+
+		if length < 1 {
+			return fmt.Errorf("received an empty context")
+		}
+
+		player.contextLength = length
+	// artists don't have a track list, so we build our own pool from their releases
+	case player.contextType == "artist":
+		artistPool, err := player.buildArtistPool(player.contextHREF, player.contextURI)
+		if err != nil {
+			return fmt.Errorf("couldn't build artist pool; %s", err.Error())
+		}
+
+		if len(artistPool) < 1 {
+			return fmt.Errorf("received an empty context")
+		}
+
+		player.poolSources = []poolSource{{uri: player.contextURI, weight: 1, trackURIs: artistPool}}
+		player.contextLength = len(artistPool)
+	// shows are only shuffled on request, and also need a pool to skip already played episodes
+	case player.contextType == "show":
+		if !util.AppConfig.ShowShuffle {
+			return fmt.Errorf("received a show context, while show shuffle is turned off")
+		}
+
+		showPool, err := player.buildShowPool(player.contextHREF)
+		if err != nil {
+			return fmt.Errorf("couldn't build show pool; %s", err.Error())
+		}
+
+		if len(showPool) < 1 {
+			return fmt.Errorf("received an empty context")
+		}
+
+		player.poolSources = []poolSource{{uri: player.contextURI, weight: 1, trackURIs: showPool}}
+		player.contextLength = len(showPool)
+	default:
+		length, err := player.getContextLength()
+		if err != nil {
+			return fmt.Errorf("couldn't get context length; %s", err.Error())
+		}
+		player.contextLength = length
 	}
-	player.contextLength = length
 
 	// if the context length is bigger than our playlist size limit, limit the playlist length to the size
 	if player.contextLength >= util.AppConfig.ShufflePlaylistSize {
@@ -327,19 +552,30 @@ func (player *Player) setContext(playbackResponse *map[string]interface{}) error
 	return nil
 }
 
+// getContextLength gets the amount of tracks in the current context from Spotify
 func (player *Player) getContextLength() (int, error) {
 	var length int
 
-	contextResponse, err := util.MakeHTTPRequest("GET", fmt.Sprintf("%s?market=%s", player.contextHREF, player.userCountry), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	contextURL := fmt.Sprintf("%s?market=%s", player.contextHREF, player.userCountry)
+
+	// we only need the total for the saved tracks, so we don't request more than one item
+	if player.contextType == "collection" {
+		contextURL += "&limit=1"
+	}
+
+	contextResponse, err := util.MakeHTTPRequest("GET", contextURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
 	if err != nil {
 		return length, fmt.Errorf("couldn't GET request context; %s", err.Error())
 	}
 
 	// depending on the context type the length is in another part of the JSON
-	if player.contextType == "album" {
+	switch player.contextType {
+	case "album":
 		length = int(contextResponse["total_tracks"].(float64))
-	} else if player.contextType == "playlist" {
+	case "playlist":
 		length = int(contextResponse["tracks"].(map[string]interface{})["total"].(float64))
+	case "collection":
+		length = int(contextResponse["total"].(float64))
 	}
 
 	if length < 1 {
@@ -349,133 +585,199 @@ func (player *Player) getContextLength() (int, error) {
 	return length, nil
 }
 
-// shuffleCheck makes sure shuffle is turned one || false is an error
-func (player *Player) shuffleCheck(playbackContextURI string) bool {
-	// if we're playing the temp playlist the shuffle state doesn't matter
-	if playbackContextURI == player.shufflePlaylistURI {
-		return true
+// removeFinishedTracks removes all tracks in the playlist before the current one, except for the configured amount of previous tracks
+func (player *Player) removeFinishedTracks(currentTrackURI string) error {
+	// check where the currently playing track is in the shuffle playlist, while ignoring manually queued songs
+	index := slices.Index(player.shufflePlaylistTrackURIs, currentTrackURI)
+	if index == -1 {
+		return nil
+	}
+
+	// going back to a previous track isn't a skip, the tracks after it stay where they are
+	if index < player.currentTrackIndex {
+		slog.Debug("moved back in shuffle playlist", "track", currentTrackURI, "context", player.contextURI)
+	}
+
+	player.currentTrackIndex = index
+
+	// we keep some previous tracks, so the user can go back to them
+	removeCount := index - util.AppConfig.PreviousTrackBuffer
+	if removeCount <= 0 {
+		return nil
 	}
 
-	// otherwise return the actual shuffle state
-	return player.shuffleState && !player.smartShuffle
+	headers := auth.UserToken.GetAccessTokenHeader()
+	headers["Content-Type"] = "application/json"
+
+	bodyData := map[string]interface{}{"tracks": []map[string]string{}}
+
+	// populate body
+	for _, uri := range player.shufflePlaylistTrackURIs[:removeCount] {
+		bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
+	}
+
+	snapshotResponse, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+	if err != nil {
+		return fmt.Errorf("couldn't DELETE request remove playlist items; %s", err.Error())
+	}
+
+	player.setSnapshotID(snapshotResponse)
+
+	// simply shorten shufflePlaylistTrackURIs to remove multiple URIs if needed
+	player.shufflePlaylistTrackURIs = player.shufflePlaylistTrackURIs[removeCount:]
+	player.currentTrackIndex -= removeCount
+
+	return nil
 }
 
-// removeFinishedTracks removes all tracks in the playlist before the current one
-func (player *Player) removeFinishedTracks(currentTrackURI string) error {
-	// check if a song has been finished/skipped, while ignoring manually queued songs
-	for index, shuffleTrackURI := range player.shufflePlaylistTrackURIs {
-		// check where the currently playing track is in the shuffle playlist
-		if currentTrackURI != shuffleTrackURI {
-			continue
+// pickUnusedTrack returns a random track of the context, that hasn't been used in the current cycle yet, or an empty string if there is none
+func (player *Player) pickUnusedTrack() (string, error) {
+	// near the end of a cycle random offsets rarely have unused tracks, so we only try a few times
+	for range maxRandomTrackMisses {
+		randomTrackURIs, err := player.getRandomContextTrackURIs()
+		if err != nil {
+			return "", fmt.Errorf("couldn't get random tracks from context; %s", err.Error())
 		}
 
-		// if the track is the first track we don't need to remove anything
-		if index == 0 {
-			break
+		for _, uri := range randomTrackURIs {
+			if !player.cycleTrackURIs[uri] {
+				return uri, nil
+			}
 		}
+	}
 
-		headers := auth.UserToken.GetAccessTokenHeader()
-		headers["Content-Type"] = "application/json"
+	// afterwards we get all tracks of the context to find the remaining ones
+	err := player.loadContextPool()
+	if err != nil {
+		return "", fmt.Errorf("couldn't load context pool; %s", err.Error())
+	}
 
-		bodyData := map[string]interface{}{"tracks": []map[string]string{}}
+	var unusedTrackURIs []string
 
-		// populate body
-		for _, uri := range player.shufflePlaylistTrackURIs {
-			if uri == shuffleTrackURI {
-				break
+	for _, source := range player.poolSources {
+		for _, uri := range source.trackURIs {
+			if !player.cycleTrackURIs[uri] {
+				unusedTrackURIs = append(unusedTrackURIs, uri)
 			}
-
-			bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
 		}
+	}
 
-		_, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
-		if err != nil {
-			return fmt.Errorf("couldn't DELETE request remove playlist items; %s", err.Error())
-		}
+	if len(unusedTrackURIs) == 0 {
+		return "", nil
+	}
+
+	return unusedTrackURIs[rand.Intn(len(unusedTrackURIs))], nil
+}
 
-		// simply shorten shufflePlaylistTrackURIs by index to remove multiple URIs if needed
-		player.shufflePlaylistTrackURIs = player.shufflePlaylistTrackURIs[index:]
+// loadContextPool gets all tracks of the context into a pool, if the context doesn't have one already
+func (player *Player) loadContextPool() error {
+	if len(player.poolSources) > 0 {
+		return nil
+	}
 
-		break
+	contextTrackURIs, err := player.getAllTrackURIs(player.contextURI)
+	if err != nil {
+		return fmt.Errorf("couldn't get all tracks of context; %s", err.Error())
 	}
 
+	player.poolSources = []poolSource{{uri: player.contextURI, weight: 1, trackURIs: contextTrackURIs}}
+
 	return nil
 }
 
-// fillShufflePlaylist fills the shuffle playlist up to the size of shufflePlaylistLength
-func (player *Player) fillShufflePlaylist() error {
-	// validate periodically that there is no missmatch between shufflePlaylistTrackURIs and the actually tracks
-	// this can only happen if the user manually removes a track from the playlist
-	// on default settings this should happen about every ~2min
-	if rand.Intn(player.shufflePlaylistLength*4) == 0 {
-		// get the current tracks in the playlist
-		currentShuffleTracks, err := player.getShufflePlaylistTrackURIs()
-		if err != nil {
-			return fmt.Errorf("couldn't get current shuffle tracks; %s", err.Error())
-		}
+// getRandomContextTrackURIs returns up to 20 track URIs, starting from a random offset in the current context
+func (player *Player) getRandomContextTrackURIs() ([]string, error) {
+	var trackURIs []string
+
+	// if we built a pool for the context we can take the tracks directly from one of its sources
+	if len(player.poolSources) > 0 {
+		source := player.pickPoolSource()
+		offset := rand.Intn(len(source.trackURIs))
+
+		return source.trackURIs[offset:min(offset+20, len(source.trackURIs))], nil
+	}
+
+	offset := rand.Intn(player.contextLength)
+
+	randomTrackURL := fmt.Sprintf("%s?market=%s&limit=%d&offset=%d", player.getContextTracksHREF(), player.userCountry, 20, offset)
+
+	randomTrackResponse, err := util.MakeHTTPRequest("GET", randomTrackURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return trackURIs, fmt.Errorf("couldn't GET request random track from context; %s", err.Error())
+	}
 
-		// check if we have a miss match between our var and spotify
-		if len(currentShuffleTracks) != len(player.shufflePlaylistTrackURIs) {
-			// set our var to spotify's values
-			player.shufflePlaylistTrackURIs = currentShuffleTracks
+	// loop over the response tracks
+	for _, item := range randomTrackResponse["items"].([]interface{}) {
+		// depending on if the context is an album or a playlist/collection the URI is in a different position in the JSON
+		if player.contextType == "album" {
+			trackURIs = append(trackURIs, item.(map[string]interface{})["uri"].(string))
+		} else if player.contextType == "playlist" || player.contextType == "collection" {
+			trackURIs = append(trackURIs, item.(map[string]interface{})["track"].(map[string]interface{})["uri"].(string))
 		}
 	}
 
+	return trackURIs, nil
+}
+
+// getContextTracksHREF returns the endpoint from which the tracks of the current context can be requested
+func (player *Player) getContextTracksHREF() string {
+	// the saved tracks endpoint already returns the tracks themselves
+	if player.contextType == "collection" {
+		return player.contextHREF
+	}
+
+	return player.contextHREF + "/tracks"
+}
+
+// fillShufflePlaylist fills the shuffle playlist up to shufflePlaylistLength tracks from the current one onwards
+func (player *Player) fillShufflePlaylist() error {
+	// the previous tracks we keep don't count towards the length
+	targetLength := player.currentTrackIndex + player.shufflePlaylistLength
+
 	// if our shuffle playlist is already full just return
-	if player.shufflePlaylistLength-len(player.shufflePlaylistTrackURIs) == 0 {
+	// the shuffle playlist can have one track more than its length, because the current track gets added on a redirect
+	if targetLength-len(player.shufflePlaylistTrackURIs) <= 0 {
 		return nil
 	}
 
-	// we use a map for easy access to figure out if a song is already in the palylist
-	var currentTracks = map[string]bool{}
 	// the slice stores only the URIs to be added to the shuffle playlist
 	var toBeAddedTracks []string
-
-	if len(player.shufflePlaylistTrackURIs) > 0 {
-		for _, uri := range player.shufflePlaylistTrackURIs {
-			currentTracks[uri] = true // the value we insert her doesn't matter, we just need a placeholder for the check later
-		}
-	}
+	startedCycle := false
 
 	// loop to add song URIs to toBeAddedTracks
-	for {
-		randomTrackURL := fmt.Sprintf("%s/tracks?market=%s&limit=%d&offset=%d", player.contextHREF, player.userCountry, 20, rand.Intn(player.contextLength))
-
-		randomTrackResponse, err := util.MakeHTTPRequest("GET", randomTrackURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	for len(player.shufflePlaylistTrackURIs)+len(toBeAddedTracks) < targetLength {
+		randomTrackURI, err := player.pickUnusedTrack()
 		if err != nil {
-			return fmt.Errorf("couldn't GET request random track from context; %s", err.Error())
+			return fmt.Errorf("couldn't pick unused track; %s", err.Error())
 		}
 
-		randomTrackURI := ""
-		var currentTrack string
-
-		// loop over the response tracks
-		for _, item := range randomTrackResponse["items"].([]interface{}) {
-			// depending on if the context is an album or a playlist the URI is in a different position in the JSON
-			if player.contextType == "album" {
-				currentTrack = item.(map[string]interface{})["uri"].(string)
-			} else if player.contextType == "playlist" {
-				currentTrack = item.(map[string]interface{})["track"].(map[string]interface{})["uri"].(string)
+		// every track of the context has been used in this cycle
+		if randomTrackURI == "" {
+			// without repeat every track only gets played once, so we stop filling the shuffle playlist
+			// if a new cycle has no unused tracks either, all playable tracks are already in the shuffle playlist
+			if player.repeatState != "context" || startedCycle {
+				player.cycleFinished = player.repeatState != "context"
+				break
 			}
 
-			if currentTracks[currentTrack] {
-				continue
-			}
+			// start a new cycle, where only the tracks still in the shuffle playlist are used
+			player.cycleTrackURIs = map[string]bool{}
 
-			randomTrackURI = currentTrack
-			break
-		}
+			for _, uri := range append(player.shufflePlaylistTrackURIs, toBeAddedTracks...) {
+				player.cycleTrackURIs[uri] = true
+			}
 
-		if randomTrackURI == "" {
+			startedCycle = true
 			continue
 		}
 
-		currentTracks[randomTrackURI] = true
+		player.cycleTrackURIs[randomTrackURI] = true
 		toBeAddedTracks = append(toBeAddedTracks, randomTrackURI)
+	}
 
-		if len(currentTracks) == player.shufflePlaylistLength {
-			break
-		}
+	// this can happen at the end of a cycle
+	if len(toBeAddedTracks) == 0 {
+		return nil
 	}
 
 	bodyData := map[string]interface{}{
@@ -483,17 +785,101 @@ func (player *Player) fillShufflePlaylist() error {
 	}
 
 	// finally add all the URIs to the shuffle playlist
-	_, err := util.MakeHTTPRequest("POST", player.shufflePlaylistHREF+"/tracks", auth.UserToken.GetAccessTokenHeader(), nil, bodyData)
+	snapshotResponse, err := util.MakeHTTPRequest("POST", player.shufflePlaylistHREF+"/tracks", auth.UserToken.GetAccessTokenHeader(), nil, bodyData)
 	if err != nil {
 		return fmt.Errorf("couldn't POST request add in new items to temp playlist; %s", err.Error())
 	}
 
+	player.setSnapshotID(snapshotResponse)
+
 	player.shufflePlaylistTrackURIs = append(player.shufflePlaylistTrackURIs, toBeAddedTracks...)
 
 	return nil
 }
 
-func (player *Player) startShufflePlaylist() error {
+// addCurrentTrack puts the currently playing track at the head of the shuffle playlist, so the redirect can continue it
+func (player *Player) addCurrentTrack(currentTrackURI string) error {
+	// the track might already be at the head from a previous redirect
+	if len(player.shufflePlaylistTrackURIs) > 0 && player.shufflePlaylistTrackURIs[0] == currentTrackURI && player.currentTrackIndex == 0 {
+		return nil
+	}
+
+	headers := auth.UserToken.GetAccessTokenHeader()
+	headers["Content-Type"] = "application/json"
+
+	// the tracks up to the current one were already played, so the current track replaces them instead of being put in front of them
+	removeURIs := slices.Clone(player.shufflePlaylistTrackURIs[:min(player.currentTrackIndex+1, len(player.shufflePlaylistTrackURIs))])
+
+	// the track might also be further back in the playlist, it mustn't get played twice
+	if slices.Contains(player.shufflePlaylistTrackURIs, currentTrackURI) && !slices.Contains(removeURIs, currentTrackURI) {
+		removeURIs = append(removeURIs, currentTrackURI)
+	}
+
+	if len(removeURIs) > 0 {
+		bodyData := map[string]interface{}{"tracks": []map[string]string{}}
+
+		for _, uri := range removeURIs {
+			bodyData["tracks"] = append(bodyData["tracks"].([]map[string]string), map[string]string{"uri": uri})
+		}
+
+		snapshotResponse, err := util.MakeHTTPRequest("DELETE", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+		if err != nil {
+			return fmt.Errorf("couldn't DELETE request played tracks from temp playlist; %s", err.Error())
+		}
+
+		player.setSnapshotID(snapshotResponse)
+
+		player.shufflePlaylistTrackURIs = slices.DeleteFunc(player.shufflePlaylistTrackURIs, func(uri string) bool {
+			return slices.Contains(removeURIs, uri)
+		})
+	}
+
+	bodyData := map[string]interface{}{
+		"uris":     []string{currentTrackURI},
+		"position": 0,
+	}
+
+	snapshotResponse, err := util.MakeHTTPRequest("POST", player.shufflePlaylistHREF+"/tracks", headers, nil, bodyData)
+	if err != nil {
+		return fmt.Errorf("couldn't POST request add current track to temp playlist; %s", err.Error())
+	}
+
+	player.setSnapshotID(snapshotResponse)
+
+	player.shufflePlaylistTrackURIs = append([]string{currentTrackURI}, player.shufflePlaylistTrackURIs...)
+	player.cycleTrackURIs[currentTrackURI] = true
+	player.currentTrackIndex = 0
+
+	return nil
+}
+
+// chunkURIs splits the URIs into chunks of at most the size, to fit the track limit of a single Spotify request
+func chunkURIs(uris []string, size int) [][]string {
+	var chunks [][]string
+
+	for start := 0; start < len(uris); start += size {
+		chunks = append(chunks, uris[start:min(start+size, len(uris))])
+	}
+
+	return chunks
+}
+
+// getCurrentProgress returns the progress of the playing track, moved forward by the time that passed since the playback was polled
+func getCurrentProgress(playbackResponse map[string]interface{}, pollTime time.Time) int {
+	progressMS := int(playbackResponse["progress_ms"].(float64)) + int(time.Since(pollTime).Milliseconds())
+
+	// the track can't progress past its end
+	return min(progressMS, int(playbackResponse["item"].(map[string]interface{})["duration_ms"].(float64)))
+}
+
+// startShufflePlaylist starts playing the shuffle playlist from its first track at the provided progress
+func (player *Player) startShufflePlaylist(progressMS int) error {
+	// turn of shuffle for the temp playlist before it starts, a poll that sees it with shuffle on would return to the context
+	_, err := util.MakeHTTPRequest("PUT", player.withDevice(baseURL+tooglePlaybackShuffleExtension+"?state=false"), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't PUT request shuffle playback; %s", err.Error())
+	}
+
 	headers := auth.UserToken.GetAccessTokenHeader()
 	headers["Content-Type"] = "application/json"
 
@@ -502,18 +888,55 @@ func (player *Player) startShufflePlaylist() error {
 		"offset": map[string]int{
 			"position": 0,
 		},
+		"position_ms": progressMS,
 	}
 
-	_, err := util.MakeHTTPRequest("PUT", baseURL+startPlaybackExtension, headers, nil, bodyData)
+	_, err = util.MakeHTTPRequest("PUT", player.withDevice(baseURL+startPlaybackExtension), headers, nil, bodyData)
 	if err != nil {
 		return fmt.Errorf("couldn't PUT request start playback; %s", err.Error())
 	}
 
-	// turn of shuffle for the temp playlist
-	_, err = util.MakeHTTPRequest("PUT", baseURL+tooglePlaybackShuffleExtension+"?state=false", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	return nil
+}
+
+// returnToContext moves the playback from the shuffle playlist back onto the original context, with or without Spotify's shuffle, and resets the context
+func (player *Player) returnToContext(currentTrackURI string, progressMS int, keepShuffle bool) error {
+	headers := auth.UserToken.GetAccessTokenHeader()
+	headers["Content-Type"] = "application/json"
+
+	bodyData := map[string]interface{}{
+		"context_uri": player.contextURI,
+	}
+
+	// Spotify only supports offsets for albums and playlists, a pool's trigger might also not contain the current track
+	if _, isPoolTrigger := getPoolConfig(player.contextURI); !isPoolTrigger && (player.contextType == "album" || player.contextType == "playlist") {
+		bodyData["offset"] = map[string]string{"uri": currentTrackURI}
+		bodyData["position_ms"] = progressMS
+	}
+
+	// turn off shuffle first, so the original context doesn't start shuffled
+	_, err := util.MakeHTTPRequest("PUT", player.withDevice(baseURL+tooglePlaybackShuffleExtension+"?state=false"), auth.UserToken.GetAccessTokenHeader(), nil, nil)
 	if err != nil {
 		return fmt.Errorf("couldn't PUT request shuffle playback; %s", err.Error())
 	}
 
+	_, err = util.MakeHTTPRequest("PUT", player.withDevice(baseURL+startPlaybackExtension), headers, nil, bodyData)
+	if err != nil {
+		return fmt.Errorf("couldn't PUT request start playback; %s", err.Error())
+	}
+
+	// turning shuffle on after the start keeps the current track and lets Spotify shuffle the rest
+	if keepShuffle {
+		_, err = util.MakeHTTPRequest("PUT", player.withDevice(baseURL+tooglePlaybackShuffleExtension+"?state=true"), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return fmt.Errorf("couldn't PUT request shuffle playback; %s", err.Error())
+		}
+	}
+
+	err = player.resetContext()
+	if err != nil {
+		return fmt.Errorf("couldn't reset context; %s", err.Error())
+	}
+
 	return nil
 }
@@ -0,0 +1,109 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// trackEndMargin is how long after the expected end of a track we poll, so Spotify already plays the next one
+const trackEndMargin = 500 * time.Millisecond
+
+// pollSchedule decides how long the main loop waits before the next poll of the playback state
+type pollSchedule struct {
+	requestSamples []requestSample
+}
+
+// requestSample is the amount of requests made since the start of the program at a point in time
+type requestSample struct {
+	count int64
+	time  time.Time
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// next returns how long to wait before the next poll, based on the last playback response and the API budget
+func (schedule *pollSchedule) next(playbackResponse map[string]interface{}) time.Duration {
+	return schedule.applyBudget(getPollInterval(playbackResponse))
+}
+
+// getPollInterval returns the interval for the playback, which is slow if nothing is playing and quick at the end of a track
+func getPollInterval(playbackResponse map[string]interface{}) time.Duration {
+	// while nothing is playing we don't need to react quickly
+	if len(playbackResponse) == 0 || !playbackResponse["is_playing"].(bool) {
+		return secondsToDuration(util.AppConfig.IdleRefreshTime)
+	}
+
+	interval := secondsToDuration(util.AppConfig.LoopRefreshTime)
+
+	item, ok := playbackResponse["item"].(map[string]interface{})
+	if !ok {
+		return interval
+	}
+
+	// if the track ends before the next poll, we poll right after its end instead
+	remainingTime := time.Duration(item["duration_ms"].(float64)-playbackResponse["progress_ms"].(float64)) * time.Millisecond
+
+	return max(min(interval, remainingTime+trackEndMargin), trackEndMargin)
+}
+
+// applyBudget extends the interval, if polling after it would exceed the configured amount of requests per minute
+func (schedule *pollSchedule) applyBudget(interval time.Duration) time.Duration {
+	now := time.Now()
+	schedule.sample(now)
+
+	if util.AppConfig.MaxRequestsPerMinute <= 0 {
+		return interval
+	}
+
+	oldestSample := schedule.requestSamples[0]
+
+	if util.RequestCount()-oldestSample.count < int64(util.AppConfig.MaxRequestsPerMinute) {
+		return interval
+	}
+
+	// wait until the requests of the oldest sample are out of the last minute
+	return max(interval, oldestSample.time.Add(time.Minute).Sub(now))
+}
+
+// hasBudget checks if another request still fits into the configured amount of requests per minute, so requests the loop makes
+// on top of its usual ones can be spread over several polls instead of exceeding the budget
+func (schedule *pollSchedule) hasBudget() bool {
+	if util.AppConfig.MaxRequestsPerMinute <= 0 {
+		return true
+	}
+
+	now := time.Now()
+
+	// all requests since the oldest sample from the last minute count against the budget
+	for _, sample := range schedule.requestSamples {
+		if now.Sub(sample.time) <= time.Minute {
+			return util.RequestCount()-sample.count < int64(util.AppConfig.MaxRequestsPerMinute)
+		}
+	}
+
+	// without a sample from the last minute, like after being dormant, the requests get counted from now on
+	schedule.sample(now)
+
+	return true
+}
+
+// sample remembers the amount of requests made until now and forgets the samples that are older than a minute
+func (schedule *pollSchedule) sample(now time.Time) {
+	schedule.requestSamples = append(schedule.requestSamples, requestSample{count: util.RequestCount(), time: now})
+
+	// only keep the samples from the last minute
+	for len(schedule.requestSamples) > 1 && now.Sub(schedule.requestSamples[0].time) > time.Minute {
+		schedule.requestSamples = schedule.requestSamples[1:]
+	}
+}
+
+// secondsToDuration converts seconds from the config into a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(int64(seconds * float64(time.Second)))
+}
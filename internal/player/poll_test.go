@@ -0,0 +1,44 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestHasBudget(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	now := time.Now()
+	count := util.RequestCount()
+
+	tests := []struct {
+		name                 string
+		maxRequestsPerMinute int
+		requestSamples       []requestSample
+		expected             bool
+	}{
+		{name: "without a budget", requestSamples: []requestSample{{count: count - 100, time: now}}, expected: true},
+		{name: "requests left", maxRequestsPerMinute: 10, requestSamples: []requestSample{{count: count - 9, time: now}}, expected: true},
+		{name: "used up", maxRequestsPerMinute: 10, requestSamples: []requestSample{{count: count - 10, time: now}}, expected: false},
+		{name: "older samples don't count", maxRequestsPerMinute: 10, requestSamples: []requestSample{{count: count - 100, time: now.Add(-2 * time.Minute)}, {count: count - 5, time: now}}, expected: true},
+		{name: "only older samples", maxRequestsPerMinute: 10, requestSamples: []requestSample{{count: count - 100, time: now.Add(-2 * time.Minute)}}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.AppConfig.MaxRequestsPerMinute = test.maxRequestsPerMinute
+			schedule := pollSchedule{requestSamples: test.requestSamples}
+
+			if hasBudget := schedule.hasBudget(); hasBudget != test.expected {
+				t.Errorf("got %t, expected %t", hasBudget, test.expected)
+			}
+		})
+	}
+}
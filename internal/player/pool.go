@@ -0,0 +1,391 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// duplicateDurationMS is how much the durations of two tracks with the same title may differ, for them to be the same song
+const duplicateDurationMS = 2000
+
+// albumTypeRanks decides which release of a song stays in an artist pool, the lowest rank wins
+var albumTypeRanks = map[string]int{
+	"album":       0,
+	"single":      1,
+	"compilation": 2,
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// poolSource is a part of a track pool, from which tracks get picked according to its weight
+type poolSource struct {
+	uri       string
+	weight    float64
+	trackURIs []string
+}
+
+// artistTrack is a track of an artist's release, with everything needed to recognize the same song on another release
+type artistTrack struct {
+	albumType  string
+	durationMS int
+	name       string
+	uri        string
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// getPoolConfig returns the pool that gets triggered by the provided context, if there is one
+func getPoolConfig(contextURI string) (util.PoolConfig, bool) {
+	for _, pool := range util.AppConfig.Pools {
+		if pool.Trigger == contextURI {
+			return pool, true
+		}
+	}
+
+	return util.PoolConfig{}, false
+}
+
+// buildPool loads the tracks of all sources of the pool onto the player and returns the amount of unique tracks in it
+func (player *Player) buildPool(pool util.PoolConfig) (int, error) {
+	var length int
+	// a track that is in multiple sources only stays in the first one, so every track can only be picked once
+	seenURIs := map[string]bool{}
+
+	for _, sourceConfig := range pool.Sources {
+		sourceURIs, err := player.getAllTrackURIs(sourceConfig.URI)
+		if err != nil {
+			return length, fmt.Errorf("couldn't get all tracks of pool source (%s); %s", sourceConfig.URI, err.Error())
+		}
+
+		source := poolSource{uri: sourceConfig.URI, weight: sourceConfig.Weight}
+
+		for _, uri := range sourceURIs {
+			if seenURIs[uri] {
+				continue
+			}
+
+			seenURIs[uri] = true
+			source.trackURIs = append(source.trackURIs, uri)
+		}
+
+		length += len(source.trackURIs)
+		player.poolSources = append(player.poolSources, source)
+	}
+
+	return length, nil
+}
+
+// pickPoolSource picks a random source of the player's pool based on their weights, sources without tracks are never picked
+func (player *Player) pickPoolSource() poolSource {
+	var totalWeight float64
+
+	for _, source := range player.poolSources {
+		if len(source.trackURIs) > 0 {
+			totalWeight += source.weight
+		}
+	}
+
+	randomWeight := rand.Float64() * totalWeight
+
+	for _, source := range player.poolSources {
+		if len(source.trackURIs) == 0 {
+			continue
+		}
+
+		if randomWeight < source.weight {
+			return source
+		}
+
+		randomWeight -= source.weight
+	}
+
+	// because of float inaccuracies we might not have picked a source yet, so we fall back to the last one with tracks
+	for index := len(player.poolSources) - 1; index >= 0; index-- {
+		if len(player.poolSources[index].trackURIs) > 0 {
+			return player.poolSources[index]
+		}
+	}
+
+	return poolSource{}
+}
+
+// getAllTrackURIs returns the URIs of all tracks from the context with the provided URI
+func (player *Player) getAllTrackURIs(contextURI string) ([]string, error) {
+	var trackURIs []string
+
+	uriParts := strings.Split(contextURI, ":")
+	contextID := uriParts[len(uriParts)-1]
+
+	// artists and shows don't have a track list, so we build it ourselves
+	switch {
+	case strings.HasPrefix(contextURI, "spotify:artist:"):
+		return player.buildArtistPool(fmt.Sprintf("%sartists/%s", baseURL, contextID), contextURI)
+	case strings.HasPrefix(contextURI, "spotify:show:"):
+		return player.buildShowPool(fmt.Sprintf("%sshows/%s", baseURL, contextID))
+	}
+
+	tracksURL := player.getTracksPageURL(contextURI)
+	if tracksURL == "" {
+		return trackURIs, fmt.Errorf("'%s' isn't a supported pool source", contextURI)
+	}
+
+	items, err := getAllPages(tracksURL)
+	if err != nil {
+		return trackURIs, fmt.Errorf("couldn't get all tracks; %s", err.Error())
+	}
+
+	for _, item := range items {
+		uri, ok := getItemTrackURI(item)
+		if ok {
+			trackURIs = append(trackURIs, uri)
+		}
+	}
+
+	return trackURIs, nil
+}
+
+// getTracksPageURL returns the first page of the tracks of the context with the provided URI, artists and shows don't have one
+func (player *Player) getTracksPageURL(contextURI string) string {
+	uriParts := strings.Split(contextURI, ":")
+	contextID := uriParts[len(uriParts)-1]
+
+	// depending on the context type the tracks are at different endpoints
+	switch {
+	case strings.HasPrefix(contextURI, "spotify:playlist:"):
+		return fmt.Sprintf("%s%s%s/tracks?market=%s&limit=%d", baseURL, getPlaylistExtension, contextID, player.userCountry, 50)
+	case strings.HasPrefix(contextURI, "spotify:album:"):
+		return fmt.Sprintf("%s%s/%s/tracks?market=%s&limit=%d", baseURL, getAlbumsExtension, contextID, player.userCountry, 50)
+	case contextID == "collection":
+		return fmt.Sprintf("%s%s?market=%s&limit=%d", baseURL, savedTracksExtension, player.userCountry, 50)
+	default:
+		return ""
+	}
+}
+
+// getItemTrackURI returns the URI of the track of a tracks page item, it isn't ok if the track isn't available anymore
+func getItemTrackURI(item map[string]interface{}) (string, bool) {
+	// album tracks are the items themselves, while playlists and saved tracks wrap them
+	if track, ok := item["track"]; ok {
+		// unavailable tracks in playlists are null
+		if track == nil {
+			return "", false
+		}
+
+		item = track.(map[string]interface{})
+	}
+
+	return item["uri"].(string), true
+}
+
+// buildArtistPool returns the deduplicated track URIs of all the artist's releases in the configured include groups
+func (player *Player) buildArtistPool(artistHREF string, artistURI string) ([]string, error) {
+	var pool []string
+	var tracks []artistTrack
+
+	albumIDs, err := player.getArtistAlbumIDs(artistHREF)
+	if err != nil {
+		return pool, fmt.Errorf("couldn't get artist albums; %s", err.Error())
+	}
+
+	// the several albums endpoint only allows 20 albums per request
+	for start := 0; start < len(albumIDs); start += 20 {
+		albumsURL := fmt.Sprintf("%s%s?market=%s&ids=%s", baseURL, getAlbumsExtension, player.userCountry, strings.Join(albumIDs[start:min(start+20, len(albumIDs))], ","))
+
+		albumsResponse, err := util.MakeHTTPRequest("GET", albumsURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return pool, fmt.Errorf("couldn't GET request several albums; %s", err.Error())
+		}
+
+		for _, album := range albumsResponse["albums"].([]interface{}) {
+			// albums that aren't available in the user's market are null
+			if album == nil {
+				continue
+			}
+
+			albumTracks, err := getAllAlbumTracks(album.(map[string]interface{})["tracks"].(map[string]interface{}))
+			if err != nil {
+				return pool, fmt.Errorf("couldn't get all album tracks; %s", err.Error())
+			}
+
+			for _, track := range albumTracks {
+				// compilations and appearances contain tracks by other artists we don't want
+				if !isTrackByArtist(track, artistURI) {
+					continue
+				}
+
+				tracks = append(tracks, artistTrack{
+					albumType:  album.(map[string]interface{})["album_type"].(string),
+					durationMS: int(track["duration_ms"].(float64)),
+					name:       track["name"].(string),
+					uri:        track["uri"].(string),
+				})
+			}
+		}
+	}
+
+	return dedupeArtistTracks(tracks), nil
+}
+
+// dedupeArtistTracks returns the URIs of the tracks, with every song only once. The same song released as a single and on an album has
+// a different URI on each, so songs are recognized by their title and duration instead and the album version is kept.
+func dedupeArtistTracks(tracks []artistTrack) []string {
+	var pool []string
+
+	// the indexes in the pool and the tracks they hold for every normalized title
+	keptTracks := map[string][]int{}
+	poolTracks := []artistTrack{}
+
+	for _, track := range tracks {
+		title := strings.Join(strings.Fields(strings.ToLower(track.name)), " ")
+		duplicateIndex := -1
+
+		for _, index := range keptTracks[title] {
+			// different songs can share a title, but not also their length
+			if poolTracks[index].uri == track.uri || abs(poolTracks[index].durationMS-track.durationMS) <= duplicateDurationMS {
+				duplicateIndex = index
+				break
+			}
+		}
+
+		if duplicateIndex == -1 {
+			keptTracks[title] = append(keptTracks[title], len(pool))
+			poolTracks = append(poolTracks, track)
+			pool = append(pool, track.uri)
+
+			continue
+		}
+
+		// the release we keep doesn't depend on the order Spotify returns them in
+		if getAlbumTypeRank(track.albumType) < getAlbumTypeRank(poolTracks[duplicateIndex].albumType) {
+			poolTracks[duplicateIndex] = track
+			pool[duplicateIndex] = track.uri
+		}
+	}
+
+	return pool
+}
+
+// getAlbumTypeRank returns the rank of the album type, unknown types rank last
+func getAlbumTypeRank(albumType string) int {
+	rank, ok := albumTypeRanks[albumType]
+	if !ok {
+		return len(albumTypeRanks)
+	}
+
+	return rank
+}
+
+// abs returns the absolute value of the number
+func abs(number int) int {
+	if number < 0 {
+		return -number
+	}
+
+	return number
+}
+
+// buildShowPool returns the episode URIs of the show, leaving out fully played episodes if configured
+func (player *Player) buildShowPool(showHREF string) ([]string, error) {
+	var pool []string
+
+	items, err := getAllPages(fmt.Sprintf("%s/episodes?market=%s&limit=%d", showHREF, player.userCountry, 50))
+	if err != nil {
+		return pool, fmt.Errorf("couldn't get all show episodes; %s", err.Error())
+	}
+
+	for _, episode := range items {
+		// the resume point is only available with the playback position scope, so it might be missing
+		resumePoint, _ := episode["resume_point"].(map[string]interface{})
+
+		if util.AppConfig.SkipPlayedEpisodes && resumePoint != nil && resumePoint["fully_played"].(bool) {
+			continue
+		}
+
+		pool = append(pool, episode["uri"].(string))
+	}
+
+	return pool, nil
+}
+
+// getArtistAlbumIDs returns the IDs of all albums of the artist, that are in the configured include groups
+func (player *Player) getArtistAlbumIDs(artistHREF string) ([]string, error) {
+	var albumIDs []string
+
+	items, err := getAllPages(fmt.Sprintf("%s/albums?market=%s&limit=%d&include_groups=%s", artistHREF, player.userCountry, 50, strings.Join(util.AppConfig.ArtistIncludeGroups, ",")))
+	if err != nil {
+		return albumIDs, fmt.Errorf("couldn't get all artist albums; %s", err.Error())
+	}
+
+	for _, item := range items {
+		albumIDs = append(albumIDs, item["id"].(string))
+	}
+
+	return albumIDs, nil
+}
+
+// getAllAlbumTracks returns all tracks of an album, starting from the tracks object of an album response
+func getAllAlbumTracks(tracksPage map[string]interface{}) ([]map[string]interface{}, error) {
+	var tracks []map[string]interface{}
+
+	for _, item := range tracksPage["items"].([]interface{}) {
+		tracks = append(tracks, item.(map[string]interface{}))
+	}
+
+	// only albums with more than 50 tracks have further pages
+	nextURL, _ := tracksPage["next"].(string)
+	if nextURL == "" {
+		return tracks, nil
+	}
+
+	nextTracks, err := getAllPages(nextURL)
+	if err != nil {
+		return tracks, fmt.Errorf("couldn't get all album tracks; %s", err.Error())
+	}
+
+	return append(tracks, nextTracks...), nil
+}
+
+// getAllPages requests a paginated endpoint and follows the next links until it has all items
+func getAllPages(pageURL string) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+
+	for pageURL != "" {
+		pageResponse, err := util.MakeHTTPRequest("GET", pageURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return items, fmt.Errorf("couldn't GET request page; %s", err.Error())
+		}
+
+		for _, item := range pageResponse["items"].([]interface{}) {
+			// items that aren't available anymore can be null
+			if item == nil {
+				continue
+			}
+
+			items = append(items, item.(map[string]interface{}))
+		}
+
+		pageURL, _ = pageResponse["next"].(string)
+	}
+
+	return items, nil
+}
+
+// isTrackByArtist checks if the artist with the provided URI is credited on the track
+func isTrackByArtist(track map[string]interface{}, artistURI string) bool {
+	for _, artist := range track["artists"].([]interface{}) {
+		if artist.(map[string]interface{})["uri"].(string) == artistURI {
+			return true
+		}
+	}
+
+	return false
+}
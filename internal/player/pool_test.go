@@ -0,0 +1,68 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"slices"
+	"testing"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestDedupeArtistTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracks   []artistTrack
+		expected []string
+	}{
+		{
+			name: "single before its album keeps the album version",
+			tracks: []artistTrack{
+				{albumType: "single", durationMS: 201000, name: "Song", uri: "spotify:track:single"},
+				{albumType: "album", durationMS: 201400, name: "Song", uri: "spotify:track:album"},
+				{albumType: "album", durationMS: 180000, name: "Other Song", uri: "spotify:track:other"},
+			},
+			expected: []string{"spotify:track:album", "spotify:track:other"},
+		},
+		{
+			name: "album before its single keeps the album version",
+			tracks: []artistTrack{
+				{albumType: "album", durationMS: 201000, name: "Song", uri: "spotify:track:album"},
+				{albumType: "single", durationMS: 200500, name: "song ", uri: "spotify:track:single"},
+			},
+			expected: []string{"spotify:track:album"},
+		},
+		{
+			name: "compilation and single keep the single",
+			tracks: []artistTrack{
+				{albumType: "compilation", durationMS: 201000, name: "Song", uri: "spotify:track:compilation"},
+				{albumType: "single", durationMS: 201000, name: "Song", uri: "spotify:track:single"},
+			},
+			expected: []string{"spotify:track:single"},
+		},
+		{
+			name: "same title with another length is another song",
+			tracks: []artistTrack{
+				{albumType: "album", durationMS: 201000, name: "Intro", uri: "spotify:track:first"},
+				{albumType: "album", durationMS: 95000, name: "Intro", uri: "spotify:track:second"},
+			},
+			expected: []string{"spotify:track:first", "spotify:track:second"},
+		},
+		{
+			name: "same uri on several releases",
+			tracks: []artistTrack{
+				{albumType: "album", durationMS: 201000, name: "Song", uri: "spotify:track:album"},
+				{albumType: "album", durationMS: 201000, name: "Song", uri: "spotify:track:album"},
+			},
+			expected: []string{"spotify:track:album"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if pool := dedupeArtistTracks(test.tracks); !slices.Equal(pool, test.expected) {
+				t.Errorf("got %v, expected %v", pool, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// getUserQueuedURIs returns the URIs of the items the user queued manually, Spotify puts them in front of the context's own items in the queue.
+// They aren't complete, if the request budget ran out before the end of the user's queue was found.
+func (player *Player) getUserQueuedURIs(playbackContextURI string) ([]string, bool, error) {
+	var userQueuedURIs []string
+
+	queueResponse, err := util.MakeHTTPRequest("GET", baseURL+queueExtension, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+	if err != nil {
+		return userQueuedURIs, false, fmt.Errorf("couldn't GET request queue; %s", err.Error())
+	}
+
+	queue, _ := queueResponse["queue"].([]interface{})
+
+	for _, item := range queue {
+		// items that aren't available anymore can be null
+		if item == nil {
+			continue
+		}
+
+		isContextItem, known, err := player.isContextItem(item.(map[string]interface{}), playbackContextURI)
+		if err != nil {
+			return userQueuedURIs, false, fmt.Errorf("couldn't check if queue item is from context; %s", err.Error())
+		}
+
+		// the rest of the context gets loaded with the next polls
+		if !known {
+			return userQueuedURIs, false, nil
+		}
+
+		// the first item from the context marks the end of the user's queue
+		if isContextItem {
+			break
+		}
+
+		userQueuedURIs = append(userQueuedURIs, item.(map[string]interface{})["uri"].(string))
+	}
+
+	return userQueuedURIs, true, nil
+}
+
+// isContextItem checks if a queue item belongs to the context that's currently playing, it isn't known if the request budget ran out
+func (player *Player) isContextItem(item map[string]interface{}, playbackContextURI string) (bool, bool, error) {
+	uri := item["uri"].(string)
+
+	// we know all tracks of the shuffle playlist
+	if playbackContextURI == player.shufflePlaylistURI {
+		return slices.Contains(player.shufflePlaylistTrackURIs, uri), true, nil
+	}
+
+	// tracks carry their album, so we don't need the album's tracks
+	if player.contextType == "album" && len(player.poolSources) == 0 {
+		album, _ := item["album"].(map[string]interface{})
+
+		return album != nil && album["uri"].(string) == player.contextURI, true, nil
+	}
+
+	// for everything else we need the tracks of the context
+	return player.hasContextTrack(uri)
+}
+
+// hasContextTrack checks if the track is in the context itself, for a pool these aren't the tracks of its sources. The tracks of the context
+// get loaded page by page only until the track is found and only as long as the request budget allows it. If the budget runs out first,
+// it isn't known yet and the next call continues where this one stopped.
+func (player *Player) hasContextTrack(uri string) (bool, bool, error) {
+	if player.contextTrackURIs == nil {
+		err := player.startContextTracks()
+		if err != nil {
+			return false, false, fmt.Errorf("couldn't start loading context tracks; %s", err.Error())
+		}
+	}
+
+	for !player.contextTrackURIs[uri] && player.contextTracksURL != "" {
+		if !player.polls.hasBudget() {
+			return false, false, nil
+		}
+
+		pageResponse, err := util.MakeHTTPRequest("GET", player.contextTracksURL, auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return false, false, fmt.Errorf("couldn't GET request context tracks page; %s", err.Error())
+		}
+
+		for _, item := range pageResponse["items"].([]interface{}) {
+			// items that aren't available anymore can be null
+			if item == nil {
+				continue
+			}
+
+			if trackURI, ok := getItemTrackURI(item.(map[string]interface{})); ok {
+				player.contextTrackURIs[trackURI] = true
+			}
+		}
+
+		player.contextTracksURL, _ = pageResponse["next"].(string)
+	}
+
+	return player.contextTrackURIs[uri], true, nil
+}
+
+// startContextTracks prepares loading the tracks of the context itself, tracks we already loaded for it don't get requested again
+func (player *Player) startContextTracks() error {
+	player.contextTrackURIs = map[string]bool{}
+
+	// without a pool, the only pool source is the context itself
+	if _, isPoolTrigger := getPoolConfig(player.contextURI); !isPoolTrigger && len(player.poolSources) > 0 {
+		for _, uri := range player.poolSources[0].trackURIs {
+			player.contextTrackURIs[uri] = true
+		}
+
+		return nil
+	}
+
+	player.contextTracksURL = player.getTracksPageURL(player.contextURI)
+	if player.contextTracksURL != "" {
+		return nil
+	}
+
+	// artists and shows that trigger a pool don't have pages of tracks, so they get built at once
+	trackURIs, err := player.getAllTrackURIs(player.contextURI)
+	if err != nil {
+		return fmt.Errorf("couldn't get all tracks of context; %s", err.Error())
+	}
+
+	for _, uri := range trackURIs {
+		player.contextTrackURIs[uri] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestHasContextTrack(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	util.AppConfig = util.Config{MaxRequestsPerMinute: 1}
+
+	// a sample from right now with one request since then, so the budget is used up and any request would fail the test
+	exhaustedPolls := pollSchedule{requestSamples: []requestSample{{count: util.RequestCount() - 1, time: time.Now()}}}
+
+	tests := []struct {
+		name          string
+		player        Player
+		uri           string
+		expectedFound bool
+		expectedKnown bool
+	}{
+		{
+			name:          "loaded track",
+			player:        Player{contextURI: "spotify:playlist:context", poolSources: []poolSource{{trackURIs: []string{"spotify:track:a", "spotify:track:b"}}}},
+			uri:           "spotify:track:b",
+			expectedFound: true,
+			expectedKnown: true,
+		},
+		{
+			name:          "not in the loaded tracks",
+			player:        Player{contextURI: "spotify:playlist:context", poolSources: []poolSource{{trackURIs: []string{"spotify:track:a"}}}},
+			uri:           "spotify:track:c",
+			expectedKnown: true,
+		},
+		{
+			name:   "unloaded page without budget",
+			player: Player{contextURI: "spotify:playlist:context"},
+			uri:    "spotify:track:a",
+		},
+		{
+			name:          "loaded before the budget ran out",
+			player:        Player{contextURI: "spotify:playlist:context", contextTrackURIs: map[string]bool{"spotify:track:a": true}, contextTracksURL: "next"},
+			uri:           "spotify:track:a",
+			expectedFound: true,
+			expectedKnown: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.player.polls = exhaustedPolls
+
+			found, known, err := test.player.hasContextTrack(test.uri)
+			if err != nil {
+				t.Fatalf("hasContextTrack returned an error: %s", err.Error())
+			}
+
+			if found != test.expectedFound || known != test.expectedKnown {
+				t.Errorf("got found %t and known %t, expected found %t and known %t", found, known, test.expectedFound, test.expectedKnown)
+			}
+		})
+	}
+}
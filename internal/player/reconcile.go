@@ -0,0 +1,81 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// reconcileShufflePlaylist checks if the shuffle playlist was changed by someone else and either repairs or adopts those changes
+func (player *Player) reconcileShufflePlaylist() error {
+	liveSnapshotID, err := player.getShufflePlaylistSnapshotID()
+	if err != nil {
+		return fmt.Errorf("couldn't get shuffle playlist snapshot id; %s", err.Error())
+	}
+
+	// all changes to the playlist were made by us
+	if liveSnapshotID == player.shufflePlaylistSnapshotID {
+		return nil
+	}
+
+	liveTrackURIs, err := player.getShufflePlaylistTrackURIs()
+	if err != nil {
+		return fmt.Errorf("couldn't get all shuffle playlist tracks; %s", err.Error())
+	}
+
+	// the snapshot can also change without the tracks changing
+	if slices.Equal(liveTrackURIs, player.shufflePlaylistTrackURIs) {
+		player.shufflePlaylistSnapshotID = liveSnapshotID
+		return nil
+	}
+
+	slog.Info("shuffle playlist was edited", "policy", util.AppConfig.PlaylistEditPolicy, "expectedTracks", len(player.shufflePlaylistTrackURIs), "foundTracks", len(liveTrackURIs))
+
+	if util.AppConfig.PlaylistEditPolicy == "adopt" {
+		player.shufflePlaylistTrackURIs = liveTrackURIs
+		player.shufflePlaylistSnapshotID = liveSnapshotID
+
+		// the added tracks count as used, so they don't get picked again in this cycle
+		for _, uri := range liveTrackURIs {
+			player.cycleTrackURIs[uri] = true
+		}
+
+		return nil
+	}
+
+	headers := auth.UserToken.GetAccessTokenHeader()
+	headers["Content-Type"] = "application/json"
+
+	// replacing all items removes foreign tracks, restores removed ones and their order at once, Spotify only takes 100 tracks per request
+	chunks := chunkURIs(player.shufflePlaylistTrackURIs, 100)
+	if len(chunks) == 0 {
+		chunks = [][]string{{}}
+	}
+
+	snapshotResponse, err := util.MakeHTTPRequest("PUT", player.shufflePlaylistHREF+"/tracks", headers, nil, map[string]interface{}{"uris": chunks[0]})
+	if err != nil {
+		return fmt.Errorf("couldn't PUT request replace shuffle playlist items; %s", err.Error())
+	}
+
+	player.setSnapshotID(snapshotResponse)
+
+	// the remaining tracks get added behind the replaced ones
+	for _, chunk := range chunks[1:] {
+		snapshotResponse, err = util.MakeHTTPRequest("POST", player.shufflePlaylistHREF+"/tracks", headers, nil, map[string]interface{}{"uris": chunk})
+		if err != nil {
+			return fmt.Errorf("couldn't POST request add shuffle playlist items; %s", err.Error())
+		}
+
+		player.setSnapshotID(snapshotResponse)
+	}
+
+	return nil
+}
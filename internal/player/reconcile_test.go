@@ -0,0 +1,219 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// fakePlaylist serves the playlist endpoints the reconciliation uses, like Spotify would for the shuffle playlist
+type fakePlaylist struct {
+	mutex      sync.Mutex
+	snapshotID string
+	trackURIs  []string
+	writes     int
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// ServeHTTP answers the snapshot, the tracks and the replacing and adding of tracks
+func (playlist *fakePlaylist) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	playlist.mutex.Lock()
+	defer playlist.mutex.Unlock()
+
+	var response map[string]interface{}
+
+	switch {
+	case request.Method == "GET" && request.URL.Path == "/playlist":
+		response = map[string]interface{}{"snapshot_id": playlist.snapshotID}
+	case request.Method == "GET" && request.URL.Path == "/playlist/tracks":
+		items := []interface{}{}
+
+		for _, uri := range playlist.trackURIs {
+			items = append(items, map[string]interface{}{"track": map[string]interface{}{"uri": uri}})
+		}
+
+		response = map[string]interface{}{"items": items, "next": nil}
+	case request.URL.Path == "/playlist/tracks":
+		var bodyData struct {
+			URIs []string `json:"uris"`
+		}
+
+		err := json.NewDecoder(request.Body).Decode(&bodyData)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// PUT replaces all items, POST adds them at the end
+		if request.Method == "PUT" {
+			playlist.trackURIs = nil
+		}
+
+		playlist.trackURIs = append(playlist.trackURIs, bodyData.URIs...)
+		playlist.writes++
+		playlist.snapshotID = fmt.Sprintf("snapshot-%d", playlist.writes)
+		response = map[string]interface{}{"snapshot_id": playlist.snapshotID}
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+
+	err := json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// useTestToken loads a token that doesn't expire during the test, so no request goes to the accounts service
+func useTestToken(t *testing.T) {
+	t.Helper()
+
+	previousToken := auth.UserToken
+	t.Cleanup(func() { auth.UserToken = previousToken })
+
+	util.AppConfig.TokenPath = filepath.Join(t.TempDir(), "token.json")
+
+	tokenData := fmt.Sprintf(`{"accessToken": "access", "expirationTime": "%s", "refreshToken": "refresh", "scopes": []}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	err := os.WriteFile(util.AppConfig.TokenPath, []byte(tokenData), 0600)
+	if err != nil {
+		t.Fatalf("couldn't write token: %s", err.Error())
+	}
+
+	auth.UserToken, err = auth.LoadToken()
+	if err != nil {
+		t.Fatalf("couldn't load token: %s", err.Error())
+	}
+}
+
+func TestReconcileShufflePlaylist(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	useTestToken(t)
+
+	var manyURIs []string
+	for index := range 150 {
+		manyURIs = append(manyURIs, fmt.Sprintf("spotify:track:%d", index))
+	}
+
+	tests := []struct {
+		name              string
+		policy            string
+		snapshotID        string
+		playerURIs        []string
+		liveURIs          []string
+		expectedLiveURIs  []string
+		expectedCycleURIs []string
+		expectedWrites    int
+	}{
+		{
+			name:             "unchanged snapshot",
+			policy:           "repair",
+			snapshotID:       "live",
+			playerURIs:       []string{"spotify:track:a", "spotify:track:b"},
+			liveURIs:         []string{"spotify:track:x"},
+			expectedLiveURIs: []string{"spotify:track:x"},
+		},
+		{
+			name:             "changed snapshot with the same tracks",
+			policy:           "repair",
+			snapshotID:       "old",
+			playerURIs:       []string{"spotify:track:a", "spotify:track:b"},
+			liveURIs:         []string{"spotify:track:a", "spotify:track:b"},
+			expectedLiveURIs: []string{"spotify:track:a", "spotify:track:b"},
+		},
+		{
+			name:             "repair a foreign, a removed and a moved track",
+			policy:           "repair",
+			snapshotID:       "old",
+			playerURIs:       []string{"spotify:track:a", "spotify:track:b", "spotify:track:c"},
+			liveURIs:         []string{"spotify:track:c", "spotify:track:x", "spotify:track:a"},
+			expectedLiveURIs: []string{"spotify:track:a", "spotify:track:b", "spotify:track:c"},
+			expectedWrites:   1,
+		},
+		{
+			name:             "repair more than 100 tracks",
+			policy:           "repair",
+			snapshotID:       "old",
+			playerURIs:       manyURIs,
+			liveURIs:         manyURIs[:120],
+			expectedLiveURIs: manyURIs,
+			expectedWrites:   2,
+		},
+		{
+			name:              "adopt",
+			policy:            "adopt",
+			snapshotID:        "old",
+			playerURIs:        []string{"spotify:track:a", "spotify:track:b"},
+			liveURIs:          []string{"spotify:track:a", "spotify:track:x"},
+			expectedLiveURIs:  []string{"spotify:track:a", "spotify:track:x"},
+			expectedCycleURIs: []string{"spotify:track:a", "spotify:track:x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.AppConfig.PlaylistEditPolicy = test.policy
+
+			playlist := &fakePlaylist{snapshotID: "live", trackURIs: slices.Clone(test.liveURIs)}
+			server := httptest.NewServer(playlist)
+			defer server.Close()
+
+			player := Player{
+				cycleTrackURIs:            map[string]bool{},
+				shufflePlaylistHREF:       server.URL + "/playlist",
+				shufflePlaylistSnapshotID: test.snapshotID,
+				shufflePlaylistTrackURIs:  slices.Clone(test.playerURIs),
+			}
+
+			err := player.reconcileShufflePlaylist()
+			if err != nil {
+				t.Fatalf("reconcileShufflePlaylist returned an error: %s", err.Error())
+			}
+
+			if !slices.Equal(playlist.trackURIs, test.expectedLiveURIs) {
+				t.Errorf("the playlist has %v, expected %v", playlist.trackURIs, test.expectedLiveURIs)
+			}
+
+			if playlist.writes != test.expectedWrites {
+				t.Errorf("got %d writes, expected %d", playlist.writes, test.expectedWrites)
+			}
+
+			// adopted tracks become the player's, repaired tracks stay the player's
+			if test.policy == "adopt" && !slices.Equal(player.shufflePlaylistTrackURIs, test.liveURIs) {
+				t.Errorf("the player has %v, expected %v", player.shufflePlaylistTrackURIs, test.liveURIs)
+			}
+
+			if test.policy == "repair" && !slices.Equal(player.shufflePlaylistTrackURIs, test.playerURIs) {
+				t.Errorf("the player has %v, expected %v", player.shufflePlaylistTrackURIs, test.playerURIs)
+			}
+
+			for _, uri := range test.expectedCycleURIs {
+				if !player.cycleTrackURIs[uri] {
+					t.Errorf("%s isn't in the cycle", uri)
+				}
+			}
+
+			// after reconciling, the next check has to find the playlist unchanged
+			if player.shufflePlaylistSnapshotID != playlist.snapshotID {
+				t.Errorf("the player has snapshot %s, expected %s", player.shufflePlaylistSnapshotID, playlist.snapshotID)
+			}
+		})
+	}
+}
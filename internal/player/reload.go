@@ -0,0 +1,45 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"log/slog"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// configReloads holds the newest reloaded config, until the main loop applies it
+var configReloads = make(chan util.Config, 1)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Reload passes a reloaded config to the main loop, which applies it between two polls
+func Reload(config util.Config) {
+	// only the newest config matters, so an older one that wasn't applied yet gets dropped
+	select {
+	case <-configReloads:
+	default:
+	}
+
+	configReloads <- config
+}
+
+// applyConfig sets the reloaded config onto AppConfig and updates everything on the player that depends on it
+func (player *Player) applyConfig(config util.Config) {
+	for _, key := range util.ApplyLiveConfig(config) {
+		slog.Warn("config change needs a restart, keeping the current value", "key", key)
+	}
+
+	// the context rules might have changed, so every context has to be checked again
+	player.contextRuleDecisions = map[string]ruleDecision{}
+
+	// the shuffle playlist's length is only calculated when a context gets loaded
+	if player.contextLength > 0 {
+		player.shufflePlaylistLength = min(util.AppConfig.ShufflePlaylistSize, player.contextLength)
+	}
+
+	slog.Info("applied reloaded config")
+}
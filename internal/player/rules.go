@@ -0,0 +1,125 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// ruleDecision is the result of checking a context against the context rules
+type ruleDecision struct {
+	allowed bool
+	reason  string
+}
+
+// contextDetails are the parts of a context, that have to be requested from Spotify for the rules to match them
+type contextDetails struct {
+	name    string
+	ownerID string
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// isContextAllowed checks the context against the context rules, the first matching rule decides and without one the context is allowed
+func (player *Player) isContextAllowed(context map[string]interface{}) (ruleDecision, error) {
+	contextURI := context["uri"].(string)
+
+	// the rules and contexts rarely change, so every context only gets checked once
+	if decision, ok := player.contextRuleDecisions[contextURI]; ok {
+		return decision, nil
+	}
+
+	contextType, _ := context["type"].(string)
+	decision := ruleDecision{allowed: true, reason: "no context rule matched"}
+
+	// the details need an extra request, so we only get them if a rule needs them
+	var details *contextDetails
+
+	for index, rule := range util.AppConfig.ContextRules {
+		if (rule.NamePattern != "" || rule.Owner != "") && details == nil {
+			contextHREF, _ := context["href"].(string)
+
+			fetchedDetails, err := player.getContextDetails(contextHREF, contextType)
+			if err != nil {
+				return decision, fmt.Errorf("couldn't get context details; %s", err.Error())
+			}
+
+			details = &fetchedDetails
+		}
+
+		if !player.matchesContextRule(rule, contextURI, contextType, details) {
+			continue
+		}
+
+		decision = ruleDecision{allowed: rule.Action == "include", reason: fmt.Sprintf("context rule %d (%s)", index, rule.Action)}
+		break
+	}
+
+	player.contextRuleDecisions[contextURI] = decision
+
+	return decision, nil
+}
+
+// matchesContextRule checks if the context fulfills all conditions of the rule, the details are only needed for name and owner conditions
+func (player *Player) matchesContextRule(rule util.ContextRule, contextURI string, contextType string, details *contextDetails) bool {
+	if rule.URI != "" && rule.URI != contextURI {
+		return false
+	}
+
+	if rule.Type != "" && rule.Type != contextType {
+		return false
+	}
+
+	if rule.Owner != "" {
+		ownerID := rule.Owner
+
+		// "self" is easier to write than the user's own id
+		if ownerID == "self" {
+			ownerID = player.userID
+		}
+
+		if details.ownerID != ownerID {
+			return false
+		}
+	}
+
+	// the pattern was validated with the config, so it always compiles
+	if rule.NamePattern != "" && !regexp.MustCompile(rule.NamePattern).MatchString(details.name) {
+		return false
+	}
+
+	return true
+}
+
+// getContextDetails requests the name and owner of the context, only playlists and Liked Songs have an owner
+func (player *Player) getContextDetails(contextHREF string, contextType string) (contextDetails, error) {
+	switch contextType {
+	case "collection":
+		return contextDetails{name: "Liked Songs", ownerID: player.userID}, nil
+	case "playlist":
+		playlistResponse, err := util.MakeHTTPRequest("GET", contextHREF+"?fields=name,owner(id)", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return contextDetails{}, fmt.Errorf("couldn't GET request playlist; %s", err.Error())
+		}
+
+		return contextDetails{
+			name:    playlistResponse["name"].(string),
+			ownerID: playlistResponse["owner"].(map[string]interface{})["id"].(string),
+		}, nil
+	default:
+		// albums, artists and shows all have a name at their endpoint, shows are only available with a market
+		contextResponse, err := util.MakeHTTPRequest("GET", fmt.Sprintf("%s?market=%s", contextHREF, player.userCountry), auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return contextDetails{}, fmt.Errorf("couldn't GET request %s; %s", contextType, err.Error())
+		}
+
+		return contextDetails{name: contextResponse["name"].(string)}, nil
+	}
+}
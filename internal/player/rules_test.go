@@ -0,0 +1,112 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// testContextRulesJSON are the rules of all tests, as they would be written in the config or TRS_CONTEXT_RULES
+const testContextRulesJSON = `[
+	{"action": "exclude", "uri": "spotify:playlist:focus"},
+	{"action": "include", "owner": "self", "namePattern": "(?i)mix"},
+	{"action": "exclude", "type": "playlist"},
+	{"action": "exclude", "type": "album", "namePattern": "^Live"}
+]`
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestIsContextAllowed(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	useTestToken(t)
+
+	err := json.Unmarshal([]byte(testContextRulesJSON), &util.AppConfig.ContextRules)
+	if err != nil {
+		t.Fatalf("couldn't decode context rules: %s", err.Error())
+	}
+
+	// the names and owners Spotify responds with for the contexts
+	contextResponses := map[string]string{
+		"/playlists/focus":    `{"name": "Focus", "owner": {"id": "me"}}`,
+		"/playlists/dailyMix": `{"name": "Daily Mix 1", "owner": {"id": "me"}}`,
+		"/playlists/otherMix": `{"name": "Party Mix", "owner": {"id": "someone"}}`,
+		"/playlists/roadTrip": `{"name": "Road Trip", "owner": {"id": "me"}}`,
+		"/albums/liveAlbum":   `{"name": "Live at the Opera"}`,
+		"/albums/studioAlbum": `{"name": "Studio Sessions"}`,
+		"/artists/someArtist": `{"name": "Live Band"}`,
+	}
+
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+
+		response, ok := contextResponses[request.URL.Path]
+		if !ok {
+			http.NotFound(writer, request)
+			return
+		}
+
+		writer.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		contextType      string
+		contextURI       string
+		expectedAllowed  bool
+		expectedReason   string
+		expectedRequests int64
+	}{
+		{name: "the first matching rule decides", contextType: "playlist", contextURI: "spotify:playlist:focus", expectedAllowed: false, expectedReason: "context rule 0 (exclude)"},
+		{name: "own mix", contextType: "playlist", contextURI: "spotify:playlist:dailyMix", expectedAllowed: true, expectedReason: "context rule 1 (include)", expectedRequests: 1},
+		{name: "someone else's mix", contextType: "playlist", contextURI: "spotify:playlist:otherMix", expectedAllowed: false, expectedReason: "context rule 2 (exclude)", expectedRequests: 1},
+		{name: "own playlist", contextType: "playlist", contextURI: "spotify:playlist:roadTrip", expectedAllowed: false, expectedReason: "context rule 2 (exclude)", expectedRequests: 1},
+		{name: "Liked Songs need no request", contextType: "collection", contextURI: "spotify:user:me:collection", expectedAllowed: true, expectedReason: "no context rule matched"},
+		{name: "live album", contextType: "album", contextURI: "spotify:album:liveAlbum", expectedAllowed: false, expectedReason: "context rule 3 (exclude)", expectedRequests: 1},
+		{name: "studio album", contextType: "album", contextURI: "spotify:album:studioAlbum", expectedAllowed: true, expectedReason: "no context rule matched", expectedRequests: 1},
+		{name: "only the type of the rule", contextType: "artist", contextURI: "spotify:artist:someArtist", expectedAllowed: true, expectedReason: "no context rule matched", expectedRequests: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uriParts := strings.Split(test.contextURI, ":")
+			context := map[string]interface{}{
+				"href": server.URL + "/" + test.contextType + "s/" + uriParts[len(uriParts)-1],
+				"type": test.contextType,
+				"uri":  test.contextURI,
+			}
+
+			player := Player{contextRuleDecisions: map[string]ruleDecision{}, userID: "me"}
+			requests.Store(0)
+
+			// the second check of the same context uses the first decision
+			for range 2 {
+				decision, err := player.isContextAllowed(context)
+				if err != nil {
+					t.Fatalf("isContextAllowed returned an error: %s", err.Error())
+				}
+
+				if decision.allowed != test.expectedAllowed || decision.reason != test.expectedReason {
+					t.Errorf("got %t (%s), expected %t (%s)", decision.allowed, decision.reason, test.expectedAllowed, test.expectedReason)
+				}
+			}
+
+			if requests.Load() != test.expectedRequests {
+				t.Errorf("got %d requests, expected %d", requests.Load(), test.expectedRequests)
+			}
+		})
+	}
+}
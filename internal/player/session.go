@@ -0,0 +1,175 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// setSnapshotID takes the snapshot id from the response of a change to the shuffle playlist
+func (player *Player) setSnapshotID(snapshotResponse map[string]interface{}) {
+	snapshotID, ok := snapshotResponse["snapshot_id"].(string)
+	if ok {
+		player.shufflePlaylistSnapshotID = snapshotID
+	}
+}
+
+// getSession returns all values needed to continue the current session after a restart
+func (player *Player) getSession() map[string]interface{} {
+	cycleTrackURIs := []string{}
+
+	for uri := range player.cycleTrackURIs {
+		cycleTrackURIs = append(cycleTrackURIs, uri)
+	}
+
+	// sort the URIs, so the same cycle always results in the same session
+	slices.Sort(cycleTrackURIs)
+
+	return map[string]interface{}{
+		"contextHREF":               player.contextHREF,
+		"contextType":               player.contextType,
+		"contextURI":                player.contextURI,
+		"cycleFinished":             player.cycleFinished,
+		"cycleTrackURIs":            cycleTrackURIs,
+		"shufflePlaylistHREF":       player.shufflePlaylistHREF,
+		"shufflePlaylistSnapshotID": player.shufflePlaylistSnapshotID,
+		"shufflePlaylistTrackURIs":  slices.Clone(player.shufflePlaylistTrackURIs),
+		"shufflePlaylistURI":        player.shufflePlaylistURI,
+	}
+}
+
+// saveSession writes the current session to its JSON, if it changed since it was last saved
+func (player *Player) saveSession() error {
+	session := player.getSession()
+
+	if reflect.DeepEqual(session, player.savedSession) {
+		return nil
+	}
+
+	err := util.WriteJSONData(util.AppConfig.SessionPath, session, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't write session data to JSON; %s", err.Error())
+	}
+
+	player.savedSession = session
+
+	return nil
+}
+
+// resumeSession loads the session of a previous execution onto the player, if the shuffle playlist still matches it
+func (player *Player) resumeSession() (bool, error) {
+	// without a session file there is nothing to continue
+	if !util.FileExists(util.AppConfig.SessionPath) {
+		return false, nil
+	}
+
+	// a session that can't be used mustn't keep us from starting, so we start without it
+	sessionMap, err := util.GetJSONData(util.AppConfig.SessionPath)
+	if err != nil {
+		slog.Warn("couldn't read session, starting without it", "path", util.AppConfig.SessionPath, "error", err)
+		return false, nil
+	}
+
+	contextHREF, _ := sessionMap["contextHREF"].(string)
+	contextType, _ := sessionMap["contextType"].(string)
+	contextURI, _ := sessionMap["contextURI"].(string)
+	sessionSnapshotID, _ := sessionMap["shufflePlaylistSnapshotID"].(string)
+	sessionShufflePlaylistURI, _ := sessionMap["shufflePlaylistURI"].(string)
+	sessionTrackURIs, tracksOK := toStringSlice(sessionMap["shufflePlaylistTrackURIs"])
+	cycleTrackURIs, cycleOK := toStringSlice(sessionMap["cycleTrackURIs"])
+
+	// there was no context, so there is nothing to continue
+	if contextURI == "" {
+		return false, nil
+	}
+
+	if contextHREF == "" || contextType == "" || !tracksOK || !cycleOK {
+		slog.Warn("session is malformed, starting without it", "path", util.AppConfig.SessionPath)
+		return false, nil
+	}
+
+	// the session was of another shuffle playlist, sessions from before it was saved don't know theirs
+	if sessionShufflePlaylistURI != "" && sessionShufflePlaylistURI != player.shufflePlaylistURI {
+		return false, nil
+	}
+
+	liveSnapshotID, err := player.getShufflePlaylistSnapshotID()
+	if err != nil {
+		return false, fmt.Errorf("couldn't get shuffle playlist snapshot id; %s", err.Error())
+	}
+
+	liveTrackURIs, err := player.getShufflePlaylistTrackURIs()
+	if err != nil {
+		return false, fmt.Errorf("couldn't get all shuffle playlist tracks; %s", err.Error())
+	}
+
+	// if the snapshot changed since our last save, we can still continue as long as the tracks are the same
+	if liveSnapshotID != sessionSnapshotID && !slices.Equal(liveTrackURIs, sessionTrackURIs) {
+		return false, nil
+	}
+
+	player.contextHREF = contextHREF
+	player.contextType = contextType
+	player.contextURI = contextURI
+	player.shufflePlaylistSnapshotID = liveSnapshotID
+	player.shufflePlaylistTrackURIs = liveTrackURIs
+
+	for _, uri := range cycleTrackURIs {
+		player.cycleTrackURIs[uri] = true
+	}
+
+	// sessions from before the cycle could finish don't have it
+	player.cycleFinished, _ = sessionMap["cycleFinished"].(bool)
+
+	// the length and pools aren't saved, because they might have changed since the last execution
+	err = player.loadContext()
+	if err != nil {
+		// the context might not be available anymore, in that case we start without the session
+		slog.Warn("couldn't load context of session, starting without it", "context", player.contextURI, "error", err)
+
+		player.contextHREF = ""
+		player.contextType = ""
+		player.contextURI = ""
+		player.cycleTrackURIs = map[string]bool{}
+		player.cycleFinished = false
+		player.poolSources = nil
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// toStringSlice converts a slice from JSON data into a string slice, it isn't ok if the data is anything else
+func toStringSlice(jsonSlice interface{}) ([]string, bool) {
+	stringSlice := []string{}
+
+	// an empty slice can be saved as null
+	if jsonSlice == nil {
+		return stringSlice, true
+	}
+
+	rawSlice, ok := jsonSlice.([]interface{})
+	if !ok {
+		return stringSlice, false
+	}
+
+	for _, value := range rawSlice {
+		text, ok := value.(string)
+		if !ok {
+			return stringSlice, false
+		}
+
+		stringSlice = append(stringSlice, text)
+	}
+
+	return stringSlice, true
+}
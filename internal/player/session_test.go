@@ -0,0 +1,76 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestResumeSessionUnusable(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	tests := []struct {
+		name    string
+		session string
+	}{
+		{name: "cut off while writing", session: `{"contextHREF": "https://api.spotify.com/v1/albums/context", "contextURI": "spotify:al`},
+		{name: "empty", session: ``},
+		{name: "context href isn't a string", session: `{"contextHREF": 1, "contextType": "album", "contextURI": "spotify:album:context"}`},
+		{name: "tracks aren't strings", session: `{"contextHREF": "href", "contextType": "album", "contextURI": "spotify:album:context", "shufflePlaylistTrackURIs": [1, 2]}`},
+		{name: "cycle isn't a list", session: `{"contextHREF": "href", "contextType": "album", "contextURI": "spotify:album:context", "cycleTrackURIs": "spotify:track:a"}`},
+		{name: "of another shuffle playlist", session: `{"contextHREF": "href", "contextType": "album", "contextURI": "spotify:album:context", "shufflePlaylistURI": "spotify:playlist:other"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.AppConfig.SessionPath = filepath.Join(t.TempDir(), "session.json")
+
+			err := os.WriteFile(util.AppConfig.SessionPath, []byte(test.session), 0644)
+			if err != nil {
+				t.Fatalf("couldn't write session: %s", err.Error())
+			}
+
+			// an unusable session returns before any request, so the player needs no shuffle playlist
+			player := Player{cycleTrackURIs: map[string]bool{}, shufflePlaylistURI: testShufflePlaylistURI}
+
+			resumed, err := player.resumeSession()
+			if err != nil || resumed {
+				t.Errorf("got resumed %t with error %v, expected to start without the session", resumed, err)
+			}
+
+			if player.contextURI != "" {
+				t.Errorf("got context %s from an unusable session", player.contextURI)
+			}
+		})
+	}
+}
+
+func TestToStringSlice(t *testing.T) {
+	tests := []struct {
+		name      string
+		jsonSlice interface{}
+		expected  []string
+		expectOK  bool
+	}{
+		{name: "strings", jsonSlice: []interface{}{"a", "b"}, expected: []string{"a", "b"}, expectOK: true},
+		{name: "null", jsonSlice: nil, expected: []string{}, expectOK: true},
+		{name: "not a list", jsonSlice: "a", expected: []string{}},
+		{name: "not only strings", jsonSlice: []interface{}{"a", 1.0}, expected: []string{"a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stringSlice, ok := toStringSlice(test.jsonSlice)
+			if ok != test.expectOK || len(stringSlice) != len(test.expected) {
+				t.Errorf("got %v (%t), expected %v (%t)", stringSlice, ok, test.expected, test.expectOK)
+			}
+		})
+	}
+}
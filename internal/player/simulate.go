@@ -0,0 +1,86 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/auth"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/events"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Simulate polls the playback like Start, but only logs what TrueRandomShuffle would do instead of doing it
+func Simulate() error {
+	userPlayer, err := newPlayer()
+	if err != nil {
+		return fmt.Errorf("couldn't create a new player; %s", err.Error())
+	}
+
+	// the shuffle playlist is only needed to recognize it, so it doesn't get found or created
+	_, err = userPlayer.loadShufflePlaylist()
+	if err != nil {
+		return fmt.Errorf("couldn't load shuffle playlist; %s", err.Error())
+	}
+
+	schedule := pollSchedule{}
+	waitTime := secondsToDuration(util.AppConfig.LoopRefreshTime)
+
+	for {
+		time.Sleep(waitTime)
+
+		playbackResponse, err := util.MakeHTTPRequest("GET", baseURL+playbackStateExtension+"?additional_types=episode", auth.UserToken.GetAccessTokenHeader(), nil, nil)
+		if err != nil {
+			return fmt.Errorf("couldn't GET request playback state; %s", err.Error())
+		}
+
+		userPlayer.events.Update(events.NewState(playbackResponse, time.Now()))
+		// nothing gets changed while simulating, so the history isn't written either
+		userPlayer.handleEvents(false)
+
+		previousState := userPlayer.state
+		newState, reason, err := userPlayer.evaluatePlayback(&playbackResponse)
+		if err != nil {
+			return fmt.Errorf("couldn't evaluate playback; %s", err.Error())
+		}
+
+		err = userPlayer.transition(newState, reason)
+		if err != nil {
+			return fmt.Errorf("couldn't transition state; %s", err.Error())
+		}
+
+		if userPlayer.state != previousState {
+			userPlayer.logSimulatedAction(playbackResponse)
+		}
+
+		waitTime = schedule.next(playbackResponse)
+	}
+}
+
+// logSimulatedAction logs what the player would do after it transitioned into its current state
+func (player *Player) logSimulatedAction(playbackResponse map[string]interface{}) {
+	switch player.state {
+	case stateWatching:
+		if playbackResponse["context"].(map[string]interface{})["uri"].(string) == player.shufflePlaylistURI {
+			slog.Info("simulate: would return to the original context", "context", player.contextURI)
+		}
+	case statePreparing:
+		contextURI := playbackResponse["context"].(map[string]interface{})["uri"].(string)
+
+		if _, isPoolTrigger := getPoolConfig(contextURI); isPoolTrigger {
+			slog.Info("simulate: would fill the shuffle playlist from the pool triggered by the context and redirect onto it", "context", contextURI)
+			break
+		}
+
+		slog.Info("simulate: would fill the shuffle playlist from the context and redirect onto it", "context", contextURI)
+	case stateShuffling:
+		slog.Info("simulate: would maintain the shuffle playlist", "context", player.contextURI)
+	case stateSuspended, stateIdle:
+		slog.Info("simulate: would leave the playback alone")
+	}
+}
@@ -0,0 +1,278 @@
+// Package player is responsible for handling the main loop of the program. It executes all functionality relevant to make TrueRandomShuffle work.
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// playerState is the state the player is in, it changes on every poll of the playback state
+type playerState int
+
+const (
+	stateIdle        playerState = iota // nothing is playing
+	stateWatching                       // a context is playing without shuffle, so we only watch it
+	statePreparing                      // a context is playing with shuffle, so the shuffle playlist gets prepared for it
+	stateRedirecting                    // the shuffle playlist is ready and the playback gets moved onto it
+	stateShuffling                      // the shuffle playlist is playing and gets maintained
+	stateSuspended                      // something is playing, but TrueRandomShuffle mustn't interfere with it
+	stateDormant                        // we're outside of the schedule's windows, so the playback doesn't get polled
+)
+
+var (
+	stateNames = map[playerState]string{
+		stateIdle:        "Idle",
+		stateWatching:    "Watching",
+		statePreparing:   "Preparing",
+		stateRedirecting: "Redirecting",
+		stateShuffling:   "Shuffling",
+		stateSuspended:   "Suspended",
+		stateDormant:     "Dormant",
+	}
+
+	// stateTransitions holds all states a state may transition into, staying in the same state is always allowed
+	stateTransitions = map[playerState][]playerState{
+		stateIdle:        {stateWatching, statePreparing, stateShuffling, stateSuspended, stateDormant},
+		stateWatching:    {stateIdle, statePreparing, stateShuffling, stateSuspended, stateDormant},
+		statePreparing:   {stateIdle, stateWatching, stateRedirecting, stateShuffling, stateSuspended, stateDormant},
+		stateRedirecting: {stateIdle, stateWatching, statePreparing, stateShuffling, stateSuspended, stateDormant},
+		stateShuffling:   {stateIdle, stateWatching, statePreparing, stateSuspended, stateDormant},
+		stateSuspended:   {stateIdle, stateWatching, statePreparing, stateShuffling, stateDormant},
+		stateDormant:     {stateIdle, stateWatching, statePreparing, stateShuffling, stateSuspended},
+	}
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// String returns the name of the state for logging
+func (state playerState) String() string {
+	return stateNames[state]
+}
+
+// transition moves the player into the new state, if the transition is defined
+func (player *Player) transition(newState playerState, reason string) error {
+	if newState == player.state {
+		return nil
+	}
+
+	if !slices.Contains(stateTransitions[player.state], newState) {
+		return fmt.Errorf("invalid state transition from %s to %s (%s)", player.state, newState, reason)
+	}
+
+	slog.Info("player state changed", "from", player.state.String(), "to", newState.String(), "reason", reason, "context", player.contextURI)
+	player.state = newState
+
+	// the playback can change in any way while we don't follow it, so the states from before mustn't be compared to the ones after.
+	// Leaving these states needs no reset, the poll that woke us up is already the first state of the stream.
+	if newState == stateDormant || newState == stateIdle {
+		player.events.Reset()
+	}
+
+	return nil
+}
+
+// evaluatePlayback decides which state the player should be in for the playback response and why
+func (player *Player) evaluatePlayback(playbackResponse *map[string]interface{}) (playerState, string, error) {
+	// check if there is a playback state
+	if len(*playbackResponse) == 0 {
+		return stateIdle, "no playback", nil
+	}
+
+	// set the default check values
+	player.setCheckValues(playbackResponse)
+
+	if !player.isPlaying {
+		return stateIdle, "playback is paused", nil
+	}
+
+	if !player.isDeviceAllowed() {
+		return stateSuspended, fmt.Sprintf("device isn't allowed (%s)", player.deviceName), nil
+	}
+
+	if player.isPrivateSession {
+		return stateSuspended, "private session", nil
+	}
+
+	if player.currentlyPlayingType != "track" && !(util.AppConfig.ShowShuffle && player.currentlyPlayingType == "episode") {
+		return stateSuspended, fmt.Sprintf("playing an unsupported type (%s)", player.currentlyPlayingType), nil
+	}
+
+	if player.repeatState == "track" {
+		return stateSuspended, "repeating a track", nil
+	}
+
+	// check if a context exists (i.e. if the user is listening to a song outside of an album/playlist)
+	if (*playbackResponse)["context"] == nil {
+		return stateSuspended, "no context", nil
+	}
+
+	// if we're playing the shuffle playlist the shuffle state doesn't matter
+	if (*playbackResponse)["context"].(map[string]interface{})["uri"].(string) == player.shufflePlaylistURI {
+		// without the original context we can't maintain the shuffle playlist
+		if player.contextURI == "" {
+			return stateSuspended, "playing the shuffle playlist without a context", nil
+		}
+
+		// we turned off shuffle when we started the shuffle playlist, so if it's on the user toggled it to leave
+		if player.shuffleState {
+			return stateWatching, "shuffle was toggled in the shuffle playlist", nil
+		}
+
+		return stateShuffling, "playing the shuffle playlist", nil
+	}
+
+	if !player.shuffleState || player.smartShuffle {
+		return stateWatching, "playing a context without shuffle", nil
+	}
+
+	// the rules decide if we shuffle the context or leave it to Spotify
+	decision, err := player.isContextAllowed((*playbackResponse)["context"].(map[string]interface{}))
+	if err != nil {
+		return stateIdle, "", fmt.Errorf("couldn't check context rules; %s", err.Error())
+	}
+
+	if !decision.allowed {
+		return stateSuspended, fmt.Sprintf("context is excluded by %s", decision.reason), nil
+	}
+
+	return statePreparing, "playing a context with shuffle", nil
+}
+
+// update evaluates the playback response polled at the provided time, transitions into the resulting state and executes everything the state requires
+func (player *Player) update(playbackResponse *map[string]interface{}, pollTime time.Time) error {
+	newState, reason, err := player.evaluatePlayback(playbackResponse)
+	if err != nil {
+		return fmt.Errorf("couldn't evaluate playback; %s", err.Error())
+	}
+
+	// playing another context ends the current shuffle playlist
+	if newState == stateWatching || newState == statePreparing {
+		err := player.clearContext((*playbackResponse)["context"].(map[string]interface{})["uri"].(string))
+		if err != nil {
+			return fmt.Errorf("couldn't clear context on player and reset shuffle playlist; %s", err.Error())
+		}
+	}
+
+	// if the context is empty we have to set it before we can prepare the shuffle playlist
+	if newState == statePreparing && player.contextURI == "" {
+		err := player.setContext(playbackResponse)
+		if err != nil {
+			return fmt.Errorf("couldn't set context; %s", err.Error())
+		}
+	}
+
+	// a context with only 1 track can't be shuffled
+	if newState == statePreparing && player.contextLength == 1 {
+		newState, reason = stateSuspended, "context only has 1 track"
+	}
+
+	err = player.transition(newState, reason)
+	if err != nil {
+		return fmt.Errorf("couldn't transition state; %s", err.Error())
+	}
+
+	switch player.state {
+	case stateWatching:
+		// if we're still in the shuffle playlist the user wants to go back to the original context
+		if (*playbackResponse)["context"].(map[string]interface{})["uri"].(string) != player.shufflePlaylistURI {
+			break
+		}
+
+		err = player.returnToContext((*playbackResponse)["item"].(map[string]interface{})["uri"].(string), int((*playbackResponse)["progress_ms"].(float64)), false)
+		if err != nil {
+			return fmt.Errorf("couldn't return to original context; %s", err.Error())
+		}
+	case statePreparing:
+		currentTrackURI := (*playbackResponse)["item"].(map[string]interface{})["uri"].(string)
+
+		// starting the shuffle playlist could discard tracks the user queued, so we wait until they were played before we prepare it
+		userQueuedURIs, complete, err := player.getUserQueuedURIs((*playbackResponse)["context"].(map[string]interface{})["uri"].(string))
+		if err != nil {
+			return fmt.Errorf("couldn't get user queued tracks; %s", err.Error())
+		}
+
+		// the request budget ran out while loading the context's tracks, the next polls continue until the user's queue is known
+		if !complete {
+			break
+		}
+
+		if len(userQueuedURIs) > 0 {
+			player.userQueuedURIs = userQueuedURIs
+			break
+		}
+
+		// the last queued track is already gone from the queue while it's playing, it mustn't end up in the shuffle playlist
+		if slices.Contains(player.userQueuedURIs, currentTrackURI) {
+			break
+		}
+
+		player.userQueuedURIs = nil
+
+		// the track that is playing right now gets continued in the shuffle playlist
+		err = player.addCurrentTrack(currentTrackURI)
+		if err != nil {
+			return fmt.Errorf("couldn't add current track to shuffle playlist; %s", err.Error())
+		}
+
+		// fill shuffle playlist up until it has shufflePlaylistLength tracks
+		err = player.fillShufflePlaylist()
+		if err != nil {
+			return fmt.Errorf("couldn't fill shuffle playlist; %s", err.Error())
+		}
+
+		err = player.transition(stateRedirecting, "shuffle playlist is ready")
+		if err != nil {
+			return fmt.Errorf("couldn't transition state; %s", err.Error())
+		}
+
+		// start playing our palylist, the track kept playing while we prepared it
+		err = player.startShufflePlaylist(getCurrentProgress(*playbackResponse, pollTime))
+		if err != nil {
+			return fmt.Errorf("couldn't start playing temp playlist; %s", err.Error())
+		}
+	case stateShuffling:
+		// make sure the shuffle playlist still has the tracks we expect
+		err = player.reconcileShufflePlaylist()
+		if err != nil {
+			return fmt.Errorf("couldn't reconcile shuffle playlist; %s", err.Error())
+		}
+
+		// remove tracks behind the currently playing one
+		err = player.removeFinishedTracks((*playbackResponse)["item"].(map[string]interface{})["uri"].(string))
+		if err != nil {
+			return fmt.Errorf("couldn't remove finished tracks; %s", err.Error())
+		}
+
+		// fill shuffle playlist up until it has shufflePlaylistLength tracks
+		err = player.fillShufflePlaylist()
+		if err != nil {
+			return fmt.Errorf("couldn't fill shuffle playlist; %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// handleEvents logs all playback events the player received since the last poll and records finished tracks in the history, if wanted
+func (player *Player) handleEvents(recordHistory bool) {
+	for {
+		select {
+		case event := <-player.playerEvents:
+			slog.Debug("playback event", "event", event)
+
+			if recordHistory {
+				player.recordHistory(event)
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package player
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/events"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/util"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+const (
+	testContextURI         = "spotify:album:context"
+	testShufflePlaylistURI = "spotify:playlist:shuffle"
+)
+
+// testPlaybackJSON is a response of the playback endpoint, while a track of the test context plays with shuffle
+const testPlaybackJSON = `{
+	"device": {"id": "device", "is_active": true, "is_private_session": false, "name": "Computer", "type": "Computer", "volume_percent": 50},
+	"repeat_state": "off",
+	"shuffle_state": true,
+	"smart_shuffle": false,
+	"context": {"type": "album", "href": "https://api.spotify.com/v1/albums/context", "uri": "spotify:album:context"},
+	"timestamp": 1767268800000,
+	"progress_ms": 60000,
+	"is_playing": true,
+	"item": {"duration_ms": 180000, "name": "Track", "type": "track", "uri": "spotify:track:a"},
+	"currently_playing_type": "track"
+}`
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestEvaluatePlayback(t *testing.T) {
+	previousConfig := util.AppConfig
+	t.Cleanup(func() { util.AppConfig = previousConfig })
+
+	util.AppConfig = util.Config{}
+
+	tests := []struct {
+		name           string
+		modify         func(playback map[string]interface{})
+		contextURI     string
+		showShuffle    bool
+		expectedState  playerState
+		expectedReason string
+	}{
+		{
+			name: "nothing playing",
+			// the endpoint responds with 204 and no body
+			modify: func(playback map[string]interface{}) {
+				clear(playback)
+			},
+			expectedState:  stateIdle,
+			expectedReason: "no playback",
+		},
+		{
+			name:           "paused",
+			modify:         func(playback map[string]interface{}) { playback["is_playing"] = false },
+			expectedState:  stateIdle,
+			expectedReason: "playback is paused",
+		},
+		{
+			name: "private session",
+			modify: func(playback map[string]interface{}) {
+				playback["device"].(map[string]interface{})["is_private_session"] = true
+			},
+			expectedState:  stateSuspended,
+			expectedReason: "private session",
+		},
+		{
+			name:           "repeating a track",
+			modify:         func(playback map[string]interface{}) { playback["repeat_state"] = "track" },
+			expectedState:  stateSuspended,
+			expectedReason: "repeating a track",
+		},
+		{
+			name:           "no context",
+			modify:         func(playback map[string]interface{}) { playback["context"] = nil },
+			expectedState:  stateSuspended,
+			expectedReason: "no context",
+		},
+		{
+			name:           "smart shuffle",
+			modify:         func(playback map[string]interface{}) { playback["smart_shuffle"] = true },
+			expectedState:  stateWatching,
+			expectedReason: "playing a context without shuffle",
+		},
+		{
+			name:           "episode with showShuffle off",
+			modify:         func(playback map[string]interface{}) { playback["currently_playing_type"] = "episode" },
+			expectedState:  stateSuspended,
+			expectedReason: "playing an unsupported type (episode)",
+		},
+		{
+			name:           "episode with showShuffle on",
+			modify:         func(playback map[string]interface{}) { playback["currently_playing_type"] = "episode" },
+			showShuffle:    true,
+			expectedState:  statePreparing,
+			expectedReason: "playing a context with shuffle",
+		},
+		{
+			name:           "context without shuffle",
+			modify:         func(playback map[string]interface{}) { playback["shuffle_state"] = false },
+			expectedState:  stateWatching,
+			expectedReason: "playing a context without shuffle",
+		},
+		{
+			name:           "context with shuffle",
+			modify:         func(playback map[string]interface{}) {},
+			expectedState:  statePreparing,
+			expectedReason: "playing a context with shuffle",
+		},
+		{
+			name: "shuffle playlist with shuffle off",
+			modify: func(playback map[string]interface{}) {
+				playback["context"] = map[string]interface{}{"type": "playlist", "uri": testShufflePlaylistURI}
+				playback["shuffle_state"] = false
+			},
+			contextURI:     testContextURI,
+			expectedState:  stateShuffling,
+			expectedReason: "playing the shuffle playlist",
+		},
+		{
+			name: "shuffle playlist with shuffle on",
+			modify: func(playback map[string]interface{}) {
+				playback["context"] = map[string]interface{}{"type": "playlist", "uri": testShufflePlaylistURI}
+			},
+			contextURI:     testContextURI,
+			expectedState:  stateWatching,
+			expectedReason: "shuffle was toggled in the shuffle playlist",
+		},
+		{
+			name: "shuffle playlist without a context",
+			modify: func(playback map[string]interface{}) {
+				playback["context"] = map[string]interface{}{"type": "playlist", "uri": testShufflePlaylistURI}
+				playback["shuffle_state"] = false
+			},
+			expectedState:  stateSuspended,
+			expectedReason: "playing the shuffle playlist without a context",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.AppConfig.ShowShuffle = test.showShuffle
+
+			var playback map[string]interface{}
+
+			err := json.Unmarshal([]byte(testPlaybackJSON), &playback)
+			if err != nil {
+				t.Fatalf("couldn't decode playback: %s", err.Error())
+			}
+
+			test.modify(playback)
+
+			player := Player{contextRuleDecisions: map[string]ruleDecision{}, contextURI: test.contextURI, shufflePlaylistURI: testShufflePlaylistURI}
+
+			state, reason, err := player.evaluatePlayback(&playback)
+			if err != nil {
+				t.Fatalf("evaluatePlayback returned an error: %s", err.Error())
+			}
+
+			if state != test.expectedState || reason != test.expectedReason {
+				t.Errorf("got %s (%s), expected %s (%s)", state, reason, test.expectedState, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name          string
+		from          playerState
+		to            playerState
+		expectedState playerState
+		expectError   bool
+	}{
+		{name: "same state", from: stateShuffling, to: stateShuffling, expectedState: stateShuffling},
+		{name: "idle to preparing", from: stateIdle, to: statePreparing, expectedState: statePreparing},
+		{name: "preparing to redirecting", from: statePreparing, to: stateRedirecting, expectedState: stateRedirecting},
+		{name: "redirecting to shuffling", from: stateRedirecting, to: stateShuffling, expectedState: stateShuffling},
+		{name: "shuffling to watching", from: stateShuffling, to: stateWatching, expectedState: stateWatching},
+		{name: "shuffling to dormant", from: stateShuffling, to: stateDormant, expectedState: stateDormant},
+		{name: "shuffling to redirecting", from: stateShuffling, to: stateRedirecting, expectedState: stateShuffling, expectError: true},
+		{name: "idle to redirecting", from: stateIdle, to: stateRedirecting, expectedState: stateIdle, expectError: true},
+		{name: "dormant to redirecting", from: stateDormant, to: stateRedirecting, expectedState: stateDormant, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := Player{events: events.NewStream(0), state: test.from}
+
+			err := player.transition(test.to, "test")
+			if (err != nil) != test.expectError {
+				t.Errorf("got error %v, expected an error: %t", err, test.expectError)
+			}
+
+			if player.state != test.expectedState {
+				t.Errorf("player is in %s, expected %s", player.state, test.expectedState)
+			}
+		})
+	}
+}
+
+func TestTransitionResetsEvents(t *testing.T) {
+	pollTime := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+	playing := func(itemURI string, progressMS int, timestamp time.Time) events.State {
+		return events.State{ContextURI: testShufflePlaylistURI, DurationMS: 180000, IsPlaying: true, ItemURI: itemURI, ProgressMS: progressMS, Timestamp: timestamp}
+	}
+
+	for _, newState := range []playerState{stateDormant, stateIdle} {
+		t.Run(newState.String(), func(t *testing.T) {
+			player := Player{events: events.NewStream(0), state: stateShuffling}
+			player.playerEvents = player.events.Subscribe(eventBufferSize)
+
+			// the track was far from its end, when we stopped following the playback for hours
+			player.events.Update(playing("a", 60000, pollTime))
+
+			err := player.transition(newState, "test")
+			if err != nil {
+				t.Fatalf("transition returned an error: %s", err.Error())
+			}
+
+			player.events.Update(playing("b", 1000, pollTime.Add(3*time.Hour)))
+
+			for len(player.playerEvents) > 0 {
+				if event := <-player.playerEvents; event.Type == events.TrackCompleted || event.Type == events.TrackSkipped {
+					t.Errorf("got %s for a track from before the %s state", event.Type, newState)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package schedule decides at which times TrueRandomShuffle is active, based on weekly time windows in a timezone.
+package schedule
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// maxDaysAhead is how many days we look ahead for the next window, a week always contains every window
+const maxDaysAhead = 8
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// Config is the layout of the schedule in config.json, without windows TrueRandomShuffle is always active
+type Config struct {
+	Timezone string         `json:"timezone"`
+	Windows  []WindowConfig `json:"windows"`
+}
+
+// WindowConfig is the layout of a time window in config.json, the times are "15:04" and days are "mon" to "sun"
+type WindowConfig struct {
+	Days  []string `json:"days"`
+	End   string   `json:"end"`
+	Start string   `json:"start"`
+}
+
+// Schedule holds the parsed windows in which TrueRandomShuffle is active
+type Schedule struct {
+	location *time.Location
+	windows  []window
+}
+
+// opening is a window on a specific day
+type opening struct {
+	start time.Time
+	end   time.Time
+}
+
+// window is a time range on some weekdays, a range that ends before it starts goes past midnight
+type window struct {
+	days  map[time.Weekday]bool
+	end   time.Duration
+	start time.Duration
+}
+
+// <---------------------------------------------------------------------------------------------------->
+
+// New parses the config into a Schedule, the errors name the window that is invalid
+func New(config Config) (*Schedule, error) {
+	schedule := Schedule{location: time.Local}
+
+	// without a timezone the system's timezone is used
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("'timezone' isn't a known timezone; %s", err.Error())
+		}
+
+		schedule.location = location
+	}
+
+	for index, windowConfig := range config.Windows {
+		newWindow := window{days: map[time.Weekday]bool{}}
+
+		// without days the window is on every day
+		if len(windowConfig.Days) == 0 {
+			for _, weekday := range weekdays {
+				newWindow.days[weekday] = true
+			}
+		}
+
+		for _, day := range windowConfig.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("'windows[%d].days' contains '%s', but only mon, tue, wed, thu, fri, sat and sun are possible", index, day)
+			}
+
+			newWindow.days[weekday] = true
+		}
+
+		var err error
+
+		newWindow.start, err = parseTimeOfDay(windowConfig.Start)
+		if err != nil {
+			return nil, fmt.Errorf("'windows[%d].start' %s", index, err.Error())
+		}
+
+		newWindow.end, err = parseTimeOfDay(windowConfig.End)
+		if err != nil {
+			return nil, fmt.Errorf("'windows[%d].end' %s", index, err.Error())
+		}
+
+		if newWindow.start == newWindow.end {
+			return nil, fmt.Errorf("'windows[%d]' starts and ends at the same time", index)
+		}
+
+		schedule.windows = append(schedule.windows, newWindow)
+	}
+
+	return &schedule, nil
+}
+
+// IsActive checks if the time is inside of any window
+func (schedule *Schedule) IsActive(currentTime time.Time) bool {
+	if len(schedule.windows) == 0 {
+		return true
+	}
+
+	for _, openTime := range schedule.getOpenings(currentTime, -1) {
+		if !openTime.start.After(currentTime) && openTime.end.After(currentTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextStart returns the next time a window opens after the provided time
+func (schedule *Schedule) NextStart(currentTime time.Time) time.Time {
+	var nextStart time.Time
+
+	for _, openTime := range schedule.getOpenings(currentTime, 0) {
+		if openTime.start.After(currentTime) && (nextStart.IsZero() || openTime.start.Before(nextStart)) {
+			nextStart = openTime.start
+		}
+	}
+
+	return nextStart
+}
+
+// getOpenings returns all openings of the windows from the provided day offset until maxDaysAhead days after the time
+func (schedule *Schedule) getOpenings(currentTime time.Time, fromDay int) []opening {
+	var openings []opening
+
+	localTime := currentTime.In(schedule.location)
+	midnight := time.Date(localTime.Year(), localTime.Month(), localTime.Day(), 0, 0, 0, 0, schedule.location)
+
+	// a window past midnight that started yesterday can still be open, so we may start a day earlier
+	for dayOffset := fromDay; dayOffset < maxDaysAhead; dayOffset++ {
+		day := midnight.AddDate(0, 0, dayOffset)
+
+		for _, currentWindow := range schedule.windows {
+			if !currentWindow.days[day.Weekday()] {
+				continue
+			}
+
+			end := currentWindow.end
+			if end < currentWindow.start {
+				end += 24 * time.Hour
+			}
+
+			openings = append(openings, opening{start: addTimeOfDay(day, currentWindow.start), end: addTimeOfDay(day, end)})
+		}
+	}
+
+	return openings
+}
+
+// addTimeOfDay adds the time of day to midnight in wall clock time, so days with daylight saving changes don't shift it
+func addTimeOfDay(midnight time.Time, timeOfDay time.Duration) time.Time {
+	days := int(timeOfDay / (24 * time.Hour))
+	timeOfDay -= time.Duration(days) * 24 * time.Hour
+
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day()+days, int(timeOfDay/time.Hour), int(timeOfDay%time.Hour/time.Minute), 0, 0, midnight.Location())
+}
+
+// parseTimeOfDay parses a "15:04" time into the duration since midnight, "24:00" is the end of the day
+func parseTimeOfDay(timeOfDay string) (time.Duration, error) {
+	if timeOfDay == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	parsedTime, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return 0, fmt.Errorf("has to be a time like '09:30', is '%s'", timeOfDay)
+	}
+
+	return time.Duration(parsedTime.Hour())*time.Hour + time.Duration(parsedTime.Minute())*time.Minute, nil
+}
@@ -0,0 +1,188 @@
+package schedule
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// testTimeLayout is how the tests write wall clock times, the weekday makes it obvious which window a time belongs to
+const testTimeLayout = "Mon 2006-01-02 15:04"
+
+// <---------------------------------------------------------------------------------------------------->
+
+// parseConfig parses the schedule like it's written in the config or TRS_SCHEDULE
+func parseConfig(t *testing.T, configJSON string) *Schedule {
+	t.Helper()
+
+	var config Config
+
+	err := json.Unmarshal([]byte(configJSON), &config)
+	if err != nil {
+		t.Fatalf("couldn't decode %s: %s", configJSON, err.Error())
+	}
+
+	schedule, err := New(config)
+	if err != nil {
+		t.Fatalf("New returned an error: %s", err.Error())
+	}
+
+	return schedule
+}
+
+// parseWallClock parses the wall clock time in the timezone of the schedule, it fails if the weekday doesn't match the date
+func parseWallClock(t *testing.T, schedule *Schedule, value string) time.Time {
+	t.Helper()
+
+	wallClock, err := time.ParseInLocation(testTimeLayout, value, schedule.location)
+	if err != nil {
+		t.Fatalf("couldn't parse %s: %s", value, err.Error())
+	}
+
+	if wallClock.Format(testTimeLayout) != value {
+		t.Fatalf("%s is %s", value, wallClock.Format(testTimeLayout))
+	}
+
+	return wallClock
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		configJSON  string
+		expectError bool
+	}{
+		{name: "no windows", configJSON: `{}`},
+		{name: "end of the day", configJSON: `{"windows": [{"start": "20:00", "end": "24:00"}]}`},
+		{name: "days in any case", configJSON: `{"windows": [{"days": ["Mon", "SAT"], "start": "09:00", "end": "17:00"}]}`},
+		{name: "unknown timezone", configJSON: `{"timezone": "Mars/Olympus"}`, expectError: true},
+		{name: "unknown day", configJSON: `{"windows": [{"days": ["monday"], "start": "09:00", "end": "17:00"}]}`, expectError: true},
+		{name: "invalid time", configJSON: `{"windows": [{"start": "9am", "end": "17:00"}]}`, expectError: true},
+		{name: "24:00 as start and end", configJSON: `{"windows": [{"start": "24:00", "end": "24:00"}]}`, expectError: true},
+		{name: "same start and end", configJSON: `{"windows": [{"start": "09:00", "end": "09:00"}]}`, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config Config
+
+			err := json.Unmarshal([]byte(test.configJSON), &config)
+			if err != nil {
+				t.Fatalf("couldn't decode %s: %s", test.configJSON, err.Error())
+			}
+
+			if _, err := New(config); (err != nil) != test.expectError {
+				t.Errorf("got error %v, expected an error: %t", err, test.expectError)
+			}
+		})
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	// Europe/Berlin skips 02:00 to 03:00 on 2026-03-29 and repeats it on 2026-10-25
+	tests := []struct {
+		name       string
+		configJSON string
+		active     []string
+		inactive   []string
+	}{
+		{
+			name:       "without windows",
+			configJSON: `{"timezone": "Europe/Berlin"}`,
+			active:     []string{"Tue 2026-01-06 03:00"},
+		},
+		{
+			name:       "workday",
+			configJSON: `{"timezone": "Europe/Berlin", "windows": [{"days": ["mon"], "start": "09:00", "end": "17:00"}]}`,
+			active:     []string{"Mon 2026-01-05 09:00", "Mon 2026-01-05 12:00", "Mon 2026-01-05 16:59"},
+			inactive:   []string{"Mon 2026-01-05 08:59", "Mon 2026-01-05 17:00", "Tue 2026-01-06 12:00"},
+		},
+		{
+			name:       "past midnight",
+			configJSON: `{"timezone": "Europe/Berlin", "windows": [{"days": ["sat"], "start": "22:00", "end": "02:00"}]}`,
+			active:     []string{"Sat 2026-01-10 23:00", "Sun 2026-01-11 01:30"},
+			inactive:   []string{"Sat 2026-01-10 21:59", "Sun 2026-01-11 02:00", "Sun 2026-01-11 23:00"},
+		},
+		{
+			name:       "until the end of the day",
+			configJSON: `{"timezone": "Europe/Berlin", "windows": [{"days": ["mon"], "start": "20:00", "end": "24:00"}]}`,
+			active:     []string{"Mon 2026-01-05 23:59"},
+			inactive:   []string{"Tue 2026-01-06 00:00"},
+		},
+		{
+			name:       "over the skipped and the repeated hour",
+			configJSON: `{"timezone": "Europe/Berlin", "windows": [{"days": ["sun"], "start": "01:00", "end": "04:00"}]}`,
+			active:     []string{"Sun 2026-03-29 03:30", "Sun 2026-10-25 02:30"},
+			inactive:   []string{"Sun 2026-03-29 04:00", "Sun 2026-10-25 04:00"},
+		},
+		{
+			name:       "every morning around the clock changes",
+			configJSON: `{"timezone": "Europe/Berlin", "windows": [{"start": "09:00", "end": "12:00"}]}`,
+			active:     []string{"Sun 2026-03-29 09:00", "Sun 2026-10-25 09:00"},
+			inactive:   []string{"Sun 2026-03-29 08:59", "Sun 2026-10-25 08:59"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule := parseConfig(t, test.configJSON)
+
+			for _, value := range test.active {
+				if !schedule.IsActive(parseWallClock(t, schedule, value)) {
+					t.Errorf("inactive on %s, expected it to be active", value)
+				}
+			}
+
+			for _, value := range test.inactive {
+				if schedule.IsActive(parseWallClock(t, schedule, value)) {
+					t.Errorf("active on %s, expected it to be inactive", value)
+				}
+			}
+		})
+	}
+}
+
+func TestNextStart(t *testing.T) {
+	workdayAndSaturdayNight := `{"timezone": "Europe/Berlin", "windows": [
+		{"days": ["mon"], "start": "09:00", "end": "17:00"},
+		{"days": ["sat"], "start": "22:00", "end": "02:00"}
+	]}`
+	sundayMorning := `{"timezone": "Europe/Berlin", "windows": [{"days": ["sun"], "start": "09:00", "end": "12:00"}]}`
+
+	tests := []struct {
+		name       string
+		configJSON string
+		from       string
+		// expected is empty, if there is no next start
+		expected string
+	}{
+		{name: "without windows", configJSON: `{"timezone": "Europe/Berlin"}`, from: "Mon 2026-01-05 12:00"},
+		{name: "later on the same day", configJSON: workdayAndSaturdayNight, from: "Mon 2026-01-05 08:00", expected: "Mon 2026-01-05 09:00"},
+		{name: "later in the week", configJSON: workdayAndSaturdayNight, from: "Mon 2026-01-05 10:00", expected: "Sat 2026-01-10 22:00"},
+		{name: "inside of a window past midnight", configJSON: workdayAndSaturdayNight, from: "Sun 2026-01-11 01:00", expected: "Mon 2026-01-12 09:00"},
+		{name: "at the start of a window", configJSON: workdayAndSaturdayNight, from: "Sat 2026-01-10 22:00", expected: "Mon 2026-01-12 09:00"},
+		{name: "across the clock springing forward", configJSON: sundayMorning, from: "Sat 2026-03-28 12:00", expected: "Sun 2026-03-29 09:00"},
+		{name: "across the clock falling back", configJSON: sundayMorning, from: "Sat 2026-10-24 12:00", expected: "Sun 2026-10-25 09:00"},
+		{name: "same window a week later", configJSON: sundayMorning, from: "Sun 2026-10-25 10:00", expected: "Sun 2026-11-01 09:00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule := parseConfig(t, test.configJSON)
+
+			var expected time.Time
+			if test.expected != "" {
+				expected = parseWallClock(t, schedule, test.expected)
+			}
+
+			if nextStart := schedule.NextStart(parseWallClock(t, schedule, test.from)); !nextStart.Equal(expected) {
+				t.Errorf("got %s, expected %s", nextStart, expected)
+			}
+		})
+	}
+}
@@ -4,28 +4,63 @@ package util
 // <---------------------------------------------------------------------------------------------------->
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/logging"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/schedule"
 	"github.com/joho/godotenv"
 )
 
 // <---------------------------------------------------------------------------------------------------->
 
+const (
+	// appName is the name of the directories in the default locations
+	appName = "SpotifyTrueRandomShuffle"
+	// currentConfigVersion is the version of the config layout we read, older configs get migrated to it
+	currentConfigVersion = 2
+)
+
 var AppConfig Config
 
+// loadedConfigPath is the path AppConfig was loaded from, so it can be reloaded
+var loadedConfigPath string
+
 // <---------------------------------------------------------------------------------------------------->
 
 // Config is a type to hold our config data
 type Config struct {
+	ArtistIncludeGroups  []string
 	CallbackPath         string
 	CallbackPort         string
+	ConfigVersion        int
+	ContextRules         []ContextRule
+	Devices              []string
 	envPath              string
-	errorLogPath         string
+	HistoryPath          string
+	IdleRefreshTime      float64
+	LogPath              string
+	Logging              logging.Config
 	LoopRefreshTime      float64
+	MaxRequestsPerMinute int
+	PlaylistEditPolicy   string
+	Pools                []PoolConfig
+	PreviousTrackBuffer  int
 	RequestAuthEveryTime bool
+	Schedule             schedule.Config
+	SessionPath          string
 	ShufflePlaylistPath  string
 	ShufflePlaylistSize  int
+	ShowShuffle          bool
+	SkipPlayedEpisodes   bool
+	TokenPath            string
 
 	ClientID       string
 	ClientSecret   string
@@ -34,56 +69,523 @@ type Config struct {
 	RedirectURI string
 }
 
-// Setup loads our config and envs onto AppConfig
-func Setup() error {
-	err := importConfig()
+// PoolConfig is a type to hold a pool, which mixes multiple sources and gets played instead of its trigger context
+type PoolConfig struct {
+	Name    string             `json:"name"`
+	Trigger string             `json:"trigger"`
+	Sources []PoolSourceConfig `json:"sources"`
+}
+
+// PoolSourceConfig is a type to hold a source of a pool and how often tracks get picked from it
+type PoolSourceConfig struct {
+	URI    string  `json:"uri"`
+	Weight float64 `json:"weight"`
+}
+
+// ContextRule is a type to hold a rule, that turns TrueRandomShuffle on or off for the contexts it matches
+type ContextRule struct {
+	Action      string `json:"action"`
+	NamePattern string `json:"namePattern"`
+	Owner       string `json:"owner"`
+	Type        string `json:"type"`
+	URI         string `json:"uri"`
+}
+
+// configFile is the layout of config.json, keys that are missing keep their default
+type configFile struct {
+	ArtistIncludeGroups  []string        `json:"artistIncludeGroups"`
+	CallbackPath         string          `json:"callbackPath"`
+	CallbackPort         string          `json:"callbackPort"`
+	ConfigVersion        int             `json:"configVersion"`
+	ContextRules         []ContextRule   `json:"contextRules"`
+	Devices              []string        `json:"devices"`
+	IdleRefreshTime      float64         `json:"idleRefreshTime"`
+	Logging              logging.Config  `json:"logging"`
+	LoopRefreshTime      float64         `json:"loopRefreshTime"`
+	MaxRequestsPerMinute int             `json:"maxRequestsPerMinute"`
+	Paths                configPaths     `json:"paths"`
+	PlaylistEditPolicy   string          `json:"playlistEditPolicy"`
+	Pools                []PoolConfig    `json:"pools"`
+	PreviousTrackBuffer  int             `json:"previousTrackBuffer"`
+	RequestAuthEveryTime bool            `json:"requestAuthEveryTime"`
+	Schedule             schedule.Config `json:"schedule"`
+	ShowShuffle          bool            `json:"showShuffle"`
+	ShufflePlaylistSize  int             `json:"shufflePlaylistSize"`
+	SkipPlayedEpisodes   bool            `json:"skipPlayedEpisodes"`
+}
+
+// configPaths is the layout of the paths in config.json, empty paths use their default location
+type configPaths struct {
+	Env string `json:"env"`
+	// ErrorLog is the path of the log before version 2, it gets migrated to Log
+	ErrorLog        string `json:"errorLog"`
+	History         string `json:"history"`
+	Log             string `json:"log"`
+	Session         string `json:"session"`
+	ShufflePlaylist string `json:"shufflePlaylist"`
+	Token           string `json:"token"`
+}
+
+// Setup loads our config from the provided path (or its default location), the TRS_* overrides and the envs onto AppConfig
+func Setup(configPath string) error {
+	configPath = findConfigPath(configPath)
+
+	config, err := LoadConfig(configPath)
 	if err != nil {
 		return err
 	}
 
-	err = loadEnv()
+	err = createStateDirs(&config)
 	if err != nil {
 		return err
 	}
 
+	AppConfig = config
+	loadedConfigPath = configPath
+
+	return nil
+}
+
+// LoadConfig loads and validates the config at the provided path with the TRS_* overrides and the envs, without changing AppConfig
+func LoadConfig(configPath string) (Config, error) {
+	config, err := importConfig(configPath)
+	if err != nil {
+		return config, err
+	}
+
+	resolvePaths(&config, filepath.Dir(configPath))
+
+	err = applyEnvOverrides(&config)
+	if err != nil {
+		return config, err
+	}
+
+	err = validateConfig(&config)
+	if err != nil {
+		return config, err
+	}
+
+	err = loadEnv(&config)
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// ApplyLiveConfig sets the new config onto AppConfig, values that need a restart keep their current value and their keys get returned
+func ApplyLiveConfig(newConfig Config) []string {
+	var restartKeys []string
+
+	// the server, the files and the credentials are only set up once on start
+	for _, value := range []struct {
+		key     string
+		current *string
+		new     *string
+	}{
+		{"callbackPath", &AppConfig.CallbackPath, &newConfig.CallbackPath},
+		{"callbackPort", &AppConfig.CallbackPort, &newConfig.CallbackPort},
+		{"paths.env", &AppConfig.envPath, &newConfig.envPath},
+		{"paths.history", &AppConfig.HistoryPath, &newConfig.HistoryPath},
+		{"paths.log", &AppConfig.LogPath, &newConfig.LogPath},
+		{"paths.session", &AppConfig.SessionPath, &newConfig.SessionPath},
+		{"paths.shufflePlaylist", &AppConfig.ShufflePlaylistPath, &newConfig.ShufflePlaylistPath},
+		{"paths.token", &AppConfig.TokenPath, &newConfig.TokenPath},
+	} {
+		if *value.current != *value.new {
+			restartKeys = append(restartKeys, value.key)
+			*value.new = *value.current
+		}
+	}
+
+	// the logger is only set up once on start
+	if AppConfig.Logging != newConfig.Logging {
+		restartKeys = append(restartKeys, "logging")
+		newConfig.Logging = AppConfig.Logging
+	}
+
+	if AppConfig.ConfigVersion != newConfig.ConfigVersion {
+		restartKeys = append(restartKeys, "configVersion")
+		newConfig.ConfigVersion = AppConfig.ConfigVersion
+	}
+
+	newConfig.ClientID = AppConfig.ClientID
+	newConfig.ClientSecret = AppConfig.ClientSecret
+	newConfig.RedirectDomain = AppConfig.RedirectDomain
+	newConfig.RedirectURI = AppConfig.RedirectURI
+
+	AppConfig = newConfig
+
+	return restartKeys
+}
+
+// ConfigPath returns the path AppConfig was loaded from
+func ConfigPath() string {
+	return loadedConfigPath
+}
+
+// findConfigPath returns the provided config path, the one from TRS_CONFIG or the default location
+func findConfigPath(configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+
+	if envPath := os.Getenv("TRS_CONFIG"); envPath != "" {
+		return envPath
+	}
+
+	// launched from the repository we keep using its config
+	if FileExists("./configs/config.json") {
+		return "./configs/config.json"
+	}
+
+	return filepath.Join(getXDGDir("XDG_CONFIG_HOME", ".config"), appName, "config.json")
+}
+
+// getXDGDir returns the XDG base directory from its env or its fallback inside the home directory
+func getXDGDir(env string, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+
+	return filepath.Join(homeDir, fallback)
+}
+
+// resolvePaths makes all relative paths relative to the config's directory and sets empty paths to their default location
+func resolvePaths(config *Config, configDir string) {
+	stateDir := filepath.Join(getXDGDir("XDG_STATE_HOME", filepath.Join(".local", "state")), appName)
+
+	for _, path := range []struct {
+		value       *string
+		defaultPath string
+	}{
+		{&config.envPath, filepath.Join(configDir, ".env")},
+		{&config.HistoryPath, filepath.Join(stateDir, "history.jsonl")},
+		{&config.LogPath, filepath.Join(stateDir, appName+".log")},
+		{&config.SessionPath, filepath.Join(stateDir, "session.json")},
+		{&config.ShufflePlaylistPath, filepath.Join(stateDir, "shufflePlaylist.json")},
+		{&config.TokenPath, filepath.Join(stateDir, "token.json")},
+	} {
+		switch {
+		case *path.value == "":
+			*path.value = path.defaultPath
+		case !filepath.IsAbs(*path.value):
+			*path.value = filepath.Join(configDir, *path.value)
+		}
+	}
+}
+
+// createStateDirs creates the directories of all files we create ourselves
+func createStateDirs(config *Config) error {
+	for _, path := range []string{config.HistoryPath, config.LogPath, config.SessionPath, config.ShufflePlaylistPath, config.TokenPath} {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return fmt.Errorf("couldn't create directory for %s; %s", path, err.Error())
+		}
+	}
+
 	return nil
 }
 
-// importConfig loads ./configs/config onto AppConfig
-func importConfig() error {
+// applyEnvOverrides replaces the values of the config with the values of their TRS_* environment variables, if they are set
+func applyEnvOverrides(config *Config) error {
+	stringOverrides := map[string]*string{
+		"TRS_CALLBACK_PATH":         &config.CallbackPath,
+		"TRS_CALLBACK_PORT":         &config.CallbackPort,
+		"TRS_ENV_PATH":              &config.envPath,
+		"TRS_HISTORY_PATH":          &config.HistoryPath,
+		"TRS_LOG_FORMAT":            &config.Logging.Format,
+		"TRS_LOG_LEVEL":             &config.Logging.Level,
+		"TRS_LOG_PATH":              &config.LogPath,
+		"TRS_PLAYLIST_EDIT_POLICY":  &config.PlaylistEditPolicy,
+		"TRS_SESSION_PATH":          &config.SessionPath,
+		"TRS_SHUFFLE_PLAYLIST_PATH": &config.ShufflePlaylistPath,
+		"TRS_TOKEN_PATH":            &config.TokenPath,
+	}
+	listOverrides := map[string]*[]string{
+		"TRS_ARTIST_INCLUDE_GROUPS": &config.ArtistIncludeGroups,
+		"TRS_DEVICES":               &config.Devices,
+	}
+	floatOverrides := map[string]*float64{
+		"TRS_IDLE_REFRESH_TIME": &config.IdleRefreshTime,
+		"TRS_LOOP_REFRESH_TIME": &config.LoopRefreshTime,
+	}
+	intOverrides := map[string]*int{
+		"TRS_LOG_MAX_AGE_DAYS":        &config.Logging.MaxAgeDays,
+		"TRS_LOG_MAX_SIZE_MB":         &config.Logging.MaxSizeMB,
+		"TRS_MAX_REQUESTS_PER_MINUTE": &config.MaxRequestsPerMinute,
+		"TRS_PREVIOUS_TRACK_BUFFER":   &config.PreviousTrackBuffer,
+		"TRS_SHUFFLE_PLAYLIST_SIZE":   &config.ShufflePlaylistSize,
+	}
+	boolOverrides := map[string]*bool{
+		"TRS_REQUEST_AUTH_EVERY_TIME": &config.RequestAuthEveryTime,
+		"TRS_SHOW_SHUFFLE":            &config.ShowShuffle,
+		"TRS_SKIP_PLAYED_EPISODES":    &config.SkipPlayedEpisodes,
+	}
+
+	for env, value := range stringOverrides {
+		if envValue, ok := os.LookupEnv(env); ok {
+			*value = envValue
+		}
+	}
+
+	// lists are comma separated, an empty env is an empty list
+	for env, value := range listOverrides {
+		if envValue, ok := os.LookupEnv(env); ok {
+			*value = []string{}
+
+			for _, item := range strings.Split(envValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*value = append(*value, item)
+				}
+			}
+		}
+	}
+
+	for env, value := range floatOverrides {
+		if envValue, ok := os.LookupEnv(env); ok {
+			parsedValue, err := strconv.ParseFloat(envValue, 64)
+			if err != nil {
+				return fmt.Errorf("couldn't parse %s as a number; %s", env, err.Error())
+			}
+
+			*value = parsedValue
+		}
+	}
+
+	for env, value := range intOverrides {
+		if envValue, ok := os.LookupEnv(env); ok {
+			parsedValue, err := strconv.Atoi(envValue)
+			if err != nil {
+				return fmt.Errorf("couldn't parse %s as a whole number; %s", env, err.Error())
+			}
+
+			*value = parsedValue
+		}
+	}
+
+	for env, value := range boolOverrides {
+		if envValue, ok := os.LookupEnv(env); ok {
+			parsedValue, err := strconv.ParseBool(envValue)
+			if err != nil {
+				return fmt.Errorf("couldn't parse %s as true or false; %s", env, err.Error())
+			}
+
+			*value = parsedValue
+		}
+	}
+
+	// pools, rules and the schedule are too nested for a flat env, so they are provided as JSON like in the config
+	for env, value := range map[string]interface{}{
+		"TRS_CONTEXT_RULES": &config.ContextRules,
+		"TRS_POOLS":         &config.Pools,
+		"TRS_SCHEDULE":      &config.Schedule,
+	} {
+		if envValue, ok := os.LookupEnv(env); ok {
+			err := json.Unmarshal([]byte(envValue), value)
+			if err != nil {
+				return fmt.Errorf("couldn't parse %s as JSON; %s", env, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// importConfig loads the config at the provided path
+func importConfig(configPath string) (Config, error) {
+	rawConfigData, err := os.ReadFile(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("couldn't read config (%s); %s", configPath, err.Error())
+	}
+
+	configData := getDefaultConfig()
+
+	// unknown keys are most likely typos, which would otherwise silently use the default
+	decoder := json.NewDecoder(bytes.NewReader(rawConfigData))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&configData)
+	if err != nil {
+		return Config{}, fmt.Errorf("couldn't decode config (%s); %s", configPath, describeJSONError(err, rawConfigData))
+	}
 
-	configData, err := GetJSONData("./configs/config.json")
+	err = migrateConfig(&configData, configPath)
 	if err != nil {
-		return fmt.Errorf("couldn't get config.json; %s", err.Error())
+		return Config{}, fmt.Errorf("couldn't migrate config (%s); %s", configPath, err.Error())
 	}
 
-	// set configData to exportable var
-	AppConfig = Config{
-		CallbackPath:         configData["callbackPath"].(string),
-		CallbackPort:         configData["callbackPort"].(string),
-		envPath:              configData["paths"].(map[string]interface{})["env"].(string),
-		errorLogPath:         configData["paths"].(map[string]interface{})["errorLog"].(string),
-		LoopRefreshTime:      configData["loopRefreshTime"].(float64),
-		RequestAuthEveryTime: configData["requestAuthEveryTime"].(bool),
-		ShufflePlaylistPath:  configData["paths"].(map[string]interface{})["shufflePlaylist"].(string),
-		ShufflePlaylistSize:  int(configData["shufflePlaylistSize"].(float64)),
+	return Config{
+		ArtistIncludeGroups:  configData.ArtistIncludeGroups,
+		CallbackPath:         configData.CallbackPath,
+		CallbackPort:         configData.CallbackPort,
+		ConfigVersion:        configData.ConfigVersion,
+		ContextRules:         configData.ContextRules,
+		Devices:              configData.Devices,
+		envPath:              configData.Paths.Env,
+		HistoryPath:          configData.Paths.History,
+		IdleRefreshTime:      configData.IdleRefreshTime,
+		LogPath:              configData.Paths.Log,
+		Logging:              configData.Logging,
+		LoopRefreshTime:      configData.LoopRefreshTime,
+		MaxRequestsPerMinute: configData.MaxRequestsPerMinute,
+		PlaylistEditPolicy:   configData.PlaylistEditPolicy,
+		Pools:                configData.Pools,
+		PreviousTrackBuffer:  configData.PreviousTrackBuffer,
+		RequestAuthEveryTime: configData.RequestAuthEveryTime,
+		Schedule:             configData.Schedule,
+		SessionPath:          configData.Paths.Session,
+		ShufflePlaylistPath:  configData.Paths.ShufflePlaylist,
+		ShufflePlaylistSize:  configData.ShufflePlaylistSize,
+		ShowShuffle:          configData.ShowShuffle,
+		SkipPlayedEpisodes:   configData.SkipPlayedEpisodes,
+		TokenPath:            configData.Paths.Token,
+	}, nil
+}
+
+// getDefaultConfig returns the config with the default value of every key
+func getDefaultConfig() configFile {
+	return configFile{
+		ArtistIncludeGroups:  []string{"album", "single"},
+		CallbackPath:         "/callback",
+		CallbackPort:         ":8080",
+		ConfigVersion:        currentConfigVersion,
+		ContextRules:         []ContextRule{},
+		Devices:              []string{},
+		IdleRefreshTime:      15.0,
+		Logging:              logging.Config{Format: "text", Level: "info", MaxAgeDays: 30, MaxSizeMB: 10},
+		LoopRefreshTime:      3.0,
+		MaxRequestsPerMinute: 120,
+		PlaylistEditPolicy:   "repair",
+		Pools:                []PoolConfig{},
+		PreviousTrackBuffer:  3,
+		RequestAuthEveryTime: true,
+		Schedule:             schedule.Config{Windows: []schedule.WindowConfig{}},
+		ShowShuffle:          false,
+		ShufflePlaylistSize:  10,
+		SkipPlayedEpisodes:   true,
+	}
+}
+
+// migrateConfig updates a config of an older version at the provided path to the current layout
+func migrateConfig(configData *configFile, configPath string) error {
+	if configData.ConfigVersion > currentConfigVersion {
+		return fmt.Errorf("configVersion %d is newer than the supported version %d, update TrueRandomShuffle", configData.ConfigVersion, currentConfigVersion)
+	}
+
+	// configs from before there was a version have the same layout as version 1, except their paths were relative to the working directory
+	if configData.ConfigVersion < 1 {
+		err := rebasePaths(&configData.Paths, getLegacyBaseDir(configPath))
+		if err != nil {
+			return fmt.Errorf("couldn't rebase paths; %s", err.Error())
+		}
+
+		configData.ConfigVersion = 1
+	}
+
+	// version 2 renamed the error log to log, because it gets all records now
+	if configData.ConfigVersion < 2 {
+		if configData.Paths.Log == "" {
+			configData.Paths.Log = configData.Paths.ErrorLog
+		}
+
+		configData.Paths.ErrorLog = ""
+		configData.ConfigVersion = 2
+	}
+
+	if configData.Paths.ErrorLog != "" {
+		return fmt.Errorf("'paths.errorLog' was renamed to 'paths.log' in configVersion 2")
 	}
 
 	return nil
 }
 
-// loadEnv imports the envs for the spotify API from the .env file on AppConfig
-func loadEnv() error {
-	// load envs into environment
-	err := godotenv.Load(AppConfig.envPath)
+// getLegacyBaseDir returns the directory the paths of a config without a version were relative to. Back then the config was always
+// read from ./configs/config.json, so a config in a configs directory was used from its parent, any other one from the working directory.
+func getLegacyBaseDir(configPath string) string {
+	configDir := filepath.Dir(configPath)
+
+	if filepath.Base(configDir) == "configs" {
+		return filepath.Dir(configDir)
+	}
+
+	return "."
+}
+
+// rebasePaths makes all relative paths absolute on the provided base directory, so they don't get resolved relative to the config anymore
+func rebasePaths(paths *configPaths, baseDir string) error {
+	absBaseDir, err := filepath.Abs(baseDir)
 	if err != nil {
-		return fmt.Errorf("couldn't load .env file; %s", err.Error())
+		return fmt.Errorf("couldn't get absolute path of %s; %s", baseDir, err.Error())
+	}
+
+	for _, path := range []*string{&paths.Env, &paths.ErrorLog, &paths.History, &paths.Log, &paths.Session, &paths.ShufflePlaylist, &paths.Token} {
+		// empty paths use their default location
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(absBaseDir, *path)
+		}
+	}
+
+	return nil
+}
+
+// describeJSONError turns an error from decoding the config into a message, that names the line or key that caused it
+func describeJSONError(err error, rawConfigData []byte) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		line := bytes.Count(rawConfigData[:syntaxErr.Offset], []byte("\n")) + 1
+
+		return fmt.Sprintf("invalid JSON in line %d; %s", line, syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("'%s' has to be %s, not a %s", typeErr.Field, describeType(typeErr.Type), typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Sprintf("unknown key %s, check its spelling", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return err.Error()
+	}
+}
+
+// describeType returns a readable name for the type of a config value
+func describeType(valueType reflect.Type) string {
+	switch valueType.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int:
+		return "a whole number"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// loadEnv imports the envs for the spotify API from the .env file onto the config
+func loadEnv(config *Config) error {
+	// under systemd or in a container the envs may already be set without a .env file
+	if FileExists(config.envPath) || os.Getenv("SPOTIFY_ID") == "" {
+		// load envs into environment
+		err := godotenv.Load(config.envPath)
+		if err != nil {
+			return fmt.Errorf("couldn't load .env file; %s", err.Error())
+		}
 	}
 
-	AppConfig.ClientID = os.Getenv("SPOTIFY_ID")
-	AppConfig.ClientSecret = os.Getenv("SPOTIFY_SECRET")
-	AppConfig.RedirectDomain = os.Getenv("SPOTIFY_REDIRECT_DOMAIN")
-	AppConfig.RedirectURI = AppConfig.RedirectDomain + AppConfig.CallbackPort + AppConfig.CallbackPath
+	config.ClientID = os.Getenv("SPOTIFY_ID")
+	config.ClientSecret = os.Getenv("SPOTIFY_SECRET")
+	config.RedirectDomain = os.Getenv("SPOTIFY_REDIRECT_DOMAIN")
+	config.RedirectURI = config.RedirectDomain + config.CallbackPort + config.CallbackPath
 
 	return nil
 }
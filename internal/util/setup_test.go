@@ -0,0 +1,113 @@
+package util
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestMigrateConfigPaths(t *testing.T) {
+	repositoryDir := t.TempDir()
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("couldn't get working directory: %s", err.Error())
+	}
+
+	legacyPaths := configPaths{Env: "./.env", ErrorLog: "./logs/error.log", ShufflePlaylist: "./configs/shufflePlaylist.json"}
+
+	tests := []struct {
+		name          string
+		configVersion int
+		configPath    string
+		paths         configPaths
+		expected      configPaths
+	}{
+		{
+			name:       "without a version in the repository",
+			configPath: filepath.Join(repositoryDir, "configs", "config.json"),
+			paths:      legacyPaths,
+			expected: configPaths{
+				Env:             filepath.Join(repositoryDir, ".env"),
+				Log:             filepath.Join(repositoryDir, "logs", "error.log"),
+				ShufflePlaylist: filepath.Join(repositoryDir, "configs", "shufflePlaylist.json"),
+			},
+		},
+		{
+			name:       "without a version somewhere else",
+			configPath: filepath.Join(repositoryDir, "config.json"),
+			paths:      legacyPaths,
+			expected: configPaths{
+				Env:             filepath.Join(workingDir, ".env"),
+				Log:             filepath.Join(workingDir, "logs", "error.log"),
+				ShufflePlaylist: filepath.Join(workingDir, "configs", "shufflePlaylist.json"),
+			},
+		},
+		{
+			name:       "without a version keeps absolute and empty paths",
+			configPath: filepath.Join(repositoryDir, "configs", "config.json"),
+			paths:      configPaths{Token: filepath.Join(repositoryDir, "token.json")},
+			expected:   configPaths{Token: filepath.Join(repositoryDir, "token.json")},
+		},
+		{
+			name:          "version 1 is already relative to the config",
+			configVersion: 1,
+			configPath:    filepath.Join(repositoryDir, "configs", "config.json"),
+			paths:         configPaths{Env: "../.env", ErrorLog: "../logs/error.log", Session: "./session.json"},
+			expected:      configPaths{Env: "../.env", Log: "../logs/error.log", Session: "./session.json"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configData := configFile{ConfigVersion: test.configVersion, Paths: test.paths}
+
+			err := migrateConfig(&configData, test.configPath)
+			if err != nil {
+				t.Fatalf("migrateConfig returned an error: %s", err.Error())
+			}
+
+			if configData.Paths != test.expected {
+				t.Errorf("got %+v, expected %+v", configData.Paths, test.expected)
+			}
+
+			if configData.ConfigVersion != currentConfigVersion {
+				t.Errorf("got configVersion %d, expected %d", configData.ConfigVersion, currentConfigVersion)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigNewerVersion(t *testing.T) {
+	configData := configFile{ConfigVersion: currentConfigVersion + 1}
+
+	if err := migrateConfig(&configData, filepath.Join("configs", "config.json")); err == nil {
+		t.Error("got no error for a config from a newer version")
+	}
+}
+
+func TestDefaultConfigMatchesShippedConfig(t *testing.T) {
+	rawConfigData, err := os.ReadFile(filepath.Join("..", "..", "configs", "config.json"))
+	if err != nil {
+		t.Fatalf("couldn't read shipped config: %s", err.Error())
+	}
+
+	var shippedConfig configFile
+
+	err = json.Unmarshal(rawConfigData, &shippedConfig)
+	if err != nil {
+		t.Fatalf("couldn't decode shipped config: %s", err.Error())
+	}
+
+	// the README promises that a missing key behaves like in the shipped config, only the paths default to other locations
+	shippedConfig.Paths = configPaths{}
+
+	if defaultConfig := getDefaultConfig(); !reflect.DeepEqual(shippedConfig, defaultConfig) {
+		t.Errorf("got defaults %+v, expected the shipped config %+v", defaultConfig, shippedConfig)
+	}
+}
@@ -9,31 +9,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// requestCount is the amount of HTTP requests made since the start of the program
+var requestCount atomic.Int64
+
 // <---------------------------------------------------------------------------------------------------->
 
-// LogError writes any error to a log file and then uses log.Fatal
-func LogError(logErr error, exitFlag bool) {
-	// open log file
-	logFile, err := os.OpenFile(AppConfig.errorLogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatal(fmt.Errorf("couldn't open log file; %s", err.Error()))
-	}
-	defer logFile.Close()
+// APIError is a type to hold an error Spotify responded with
+type APIError struct {
+	Method  string
+	Message string
+	Status  int
+}
 
-	// write to log file
-	fmt.Fprintf(logFile, "%v: %v\n", time.Now(), logErr)
+// Error returns the error message
+func (apiErr *APIError) Error() string {
+	return fmt.Sprintf("spotify responded with an error %d to %s request; %s", apiErr.Status, apiErr.Method, apiErr.Message)
+}
 
-	if exitFlag {
-		log.Fatal(logErr)
-	}
+// <---------------------------------------------------------------------------------------------------->
+
+// RequestCount returns the amount of HTTP requests made since the start of the program
+func RequestCount() int64 {
+	return requestCount.Load()
 }
 
 // GenerateRandomString generates a random string of Base64 characters
@@ -44,7 +51,8 @@ func GenerateRandomString(length int) string {
 	// populate it with random bytes
 	_, err := rand.Read(bytes)
 	if err != nil {
-		LogError(fmt.Errorf("couldn't rand read bytes for random string; %s", err.Error()), true)
+		// without randomness nothing we generate is safe to use, so there is nothing to recover
+		panic(fmt.Errorf("couldn't rand read bytes for random string; %s", err.Error()))
 	}
 
 	return base64.StdEncoding.EncodeToString(bytes)
@@ -76,27 +84,58 @@ func GetJSONData(filePath string) (map[string]interface{}, error) {
 	return jsonData, nil
 }
 
-// WriteJSONData will take a map with JSON data and the file path and write to that file
-func WriteJSONData(filePath string, inputData map[string]interface{}) error {
-	// open JSON file in write-only and truncate mode
-	jsonFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("couldn't open JSON file (%s); %s", filePath, err.Error())
-	}
-	defer jsonFile.Close()
+// FileExists checks if there is a file at the provided path
+func FileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+
+	return err == nil
+}
 
+// WriteJSONData will take a map with JSON data and the file path and write to that file with the provided permissions.
+// The data is written to a temporary file first, that replaces the file at once, so a crash can't leave a cut off file behind.
+func WriteJSONData(filePath string, inputData map[string]interface{}, permissions os.FileMode) error {
 	// marshal the map into JSON
 	jsonData, err := json.MarshalIndent(inputData, "", "	")
 	if err != nil {
 		return fmt.Errorf("couldn't marshal JSON data; %s", err.Error())
 	}
 
+	// the temporary file has to be in the same directory, so it can be renamed onto the file
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("couldn't create temporary JSON file for %s; %s", filePath, err.Error())
+	}
+	// after the rename there is nothing left to remove
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	err = tempFile.Chmod(permissions)
+	if err != nil {
+		return fmt.Errorf("couldn't set permissions of JSON file (%s); %s", filePath, err.Error())
+	}
+
 	// write jsonData to file
-	_, err = jsonFile.Write(jsonData)
+	_, err = tempFile.Write(jsonData)
 	if err != nil {
 		return fmt.Errorf("couldn't write JSON data to JSON file (%s); %s", filePath, err.Error())
 	}
 
+	// the data has to be on the disk before the rename, or a crash could still leave an empty file
+	err = tempFile.Sync()
+	if err != nil {
+		return fmt.Errorf("couldn't sync JSON file (%s); %s", filePath, err.Error())
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return fmt.Errorf("couldn't close JSON file (%s); %s", filePath, err.Error())
+	}
+
+	err = os.Rename(tempFile.Name(), filePath)
+	if err != nil {
+		return fmt.Errorf("couldn't replace JSON file (%s); %s", filePath, err.Error())
+	}
+
 	return nil
 }
 
@@ -148,12 +187,17 @@ func MakeHTTPRequest(method string, requestURL string, headers map[string]string
 	}
 
 	// execute the request
+	requestCount.Add(1)
+	requestTime := time.Now()
 	response, err := httpClient.Do(request)
 	if err != nil {
 		return responseMap, fmt.Errorf("couldn't receive %s request response; %s", method, err.Error())
 	}
 	defer response.Body.Close()
 
+	// the query is left out, because it can be long and may contain ids
+	slog.Debug("spotify request", "method", method, "endpoint", request.URL.Host+request.URL.Path, "status", response.StatusCode, "duration", time.Since(requestTime))
+
 	// read the response
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
@@ -171,7 +215,22 @@ func MakeHTTPRequest(method string, requestURL string, headers map[string]string
 	// check if we got an error code as a response
 	_, notOK := responseMap["error"]
 	if notOK {
-		return responseMap, fmt.Errorf("spotify responded with an error %d to %s request; %s", int(responseMap["error"].(map[string]interface{})["status"].(float64)), method, responseMap["error"].(map[string]interface{})["message"].(string))
+		// the accounts service responds with the error as a string and a separate description
+		if errorCode, ok := responseMap["error"].(string); ok {
+			description, _ := responseMap["error_description"].(string)
+
+			return responseMap, &APIError{
+				Method:  method,
+				Message: fmt.Sprintf("%s (%s)", description, errorCode),
+				Status:  response.StatusCode,
+			}
+		}
+
+		return responseMap, &APIError{
+			Method:  method,
+			Message: responseMap["error"].(map[string]interface{})["message"].(string),
+			Status:  int(responseMap["error"].(map[string]interface{})["status"].(float64)),
+		}
 	}
 
 	return responseMap, nil
@@ -0,0 +1,50 @@
+package util
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestWriteJSONData(t *testing.T) {
+	jsonDir := t.TempDir()
+	jsonPath := filepath.Join(jsonDir, "token.json")
+
+	// an existing file gets replaced with the new permissions
+	err := os.WriteFile(jsonPath, []byte(`{"accessToken": "old", "refreshToken": "old"}`), 0644)
+	if err != nil {
+		t.Fatalf("couldn't write JSON: %s", err.Error())
+	}
+
+	err = WriteJSONData(jsonPath, map[string]interface{}{"accessToken": "new"}, 0600)
+	if err != nil {
+		t.Fatalf("WriteJSONData returned an error: %s", err.Error())
+	}
+
+	jsonData, err := GetJSONData(jsonPath)
+	if err != nil {
+		t.Fatalf("couldn't read written JSON: %s", err.Error())
+	}
+
+	if len(jsonData) != 1 || jsonData["accessToken"] != "new" {
+		t.Errorf("got %v, expected only the new access token", jsonData)
+	}
+
+	fileInfo, err := os.Stat(jsonPath)
+	if err != nil {
+		t.Fatalf("couldn't stat written JSON: %s", err.Error())
+	}
+
+	if fileInfo.Mode().Perm() != 0600 {
+		t.Errorf("got permissions %s, expected %s", fileInfo.Mode().Perm(), os.FileMode(0600))
+	}
+
+	// the temporary file is gone after the rename
+	if entries, _ := os.ReadDir(jsonDir); len(entries) != 1 {
+		t.Errorf("got %d files in the directory, expected only the JSON", len(entries))
+	}
+}
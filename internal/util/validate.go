@@ -0,0 +1,193 @@
+// Package util carries many smaller utility functions that get reused over the whole project.
+package util
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/logging"
+	"github.com/SkillpTm/SpotifyTrueRandomShuffle/internal/schedule"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+var (
+	// artistIncludeGroups are all groups Spotify sorts the releases of an artist into
+	artistIncludeGroups = []string{"album", "single", "compilation", "appears_on"}
+	// contextRuleActions are all ways a context rule can decide
+	contextRuleActions = []string{"include", "exclude"}
+	// contextTypes are all types of contexts a rule can match
+	contextTypes = []string{"album", "artist", "collection", "playlist", "show"}
+	// callbackPortPattern matches a port in the ":8080" format the HTTP server expects
+	callbackPortPattern = regexp.MustCompile(`^:(\d{1,5})$`)
+	// playlistEditPolicies are all ways to handle edits to the shuffle playlist
+	playlistEditPolicies = []string{"repair", "adopt"}
+	// poolURIPrefixes are the prefixes of all contexts a pool can be triggered by and filled from, besides the Liked Songs
+	poolURIPrefixes = []string{"spotify:album:", "spotify:artist:", "spotify:playlist:", "spotify:show:"}
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+// validateConfig checks every value of the config and returns all problems at once, each naming its key
+func validateConfig(config *Config) error {
+	var problems []error
+
+	invalid := func(key string, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("'%s' %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	for _, group := range config.ArtistIncludeGroups {
+		if !slices.Contains(artistIncludeGroups, group) {
+			invalid("artistIncludeGroups", "contains '%s', but only %v are possible", group, artistIncludeGroups)
+		}
+	}
+
+	if len(config.ArtistIncludeGroups) == 0 {
+		invalid("artistIncludeGroups", "has to contain at least one group")
+	}
+
+	if len(config.CallbackPath) == 0 || config.CallbackPath[0] != '/' {
+		invalid("callbackPath", "has to start with '/', is '%s'", config.CallbackPath)
+	}
+
+	if portMatch := callbackPortPattern.FindStringSubmatch(config.CallbackPort); portMatch == nil {
+		invalid("callbackPort", "has to be a port like ':8080', is '%s'", config.CallbackPort)
+	} else if port, _ := strconv.Atoi(portMatch[1]); port < 1 || port > 65535 {
+		invalid("callbackPort", "has to be between 1 and 65535, is %d", port)
+	}
+
+	for index, rule := range config.ContextRules {
+		key := fmt.Sprintf("contextRules[%d]", index)
+
+		if !slices.Contains(contextRuleActions, rule.Action) {
+			invalid(key+".action", "has to be one of %v, is '%s'", contextRuleActions, rule.Action)
+		}
+
+		// a rule without conditions would match every context
+		if rule.NamePattern == "" && rule.Owner == "" && rule.Type == "" && rule.URI == "" {
+			invalid(key, "needs at least one of namePattern, owner, type or uri")
+		}
+
+		if _, err := regexp.Compile(rule.NamePattern); err != nil {
+			invalid(key+".namePattern", "isn't a valid regular expression; %s", err.Error())
+		}
+
+		if rule.Type != "" && !slices.Contains(contextTypes, rule.Type) {
+			invalid(key+".type", "has to be one of %v, is '%s'", contextTypes, rule.Type)
+		}
+	}
+
+	if config.IdleRefreshTime <= 0 {
+		invalid("idleRefreshTime", "has to be greater than 0, is %g", config.IdleRefreshTime)
+	}
+
+	if !slices.Contains(logging.Formats, config.Logging.Format) {
+		invalid("logging.format", "has to be one of %v, is '%s'", logging.Formats, config.Logging.Format)
+	}
+
+	if _, err := logging.ParseLevel(config.Logging.Level); err != nil {
+		problems = append(problems, fmt.Errorf("'logging.level': %s", err.Error()))
+	}
+
+	if config.Logging.MaxAgeDays < 0 {
+		invalid("logging.maxAgeDays", "can't be negative (0 keeps rotated logs forever), is %d", config.Logging.MaxAgeDays)
+	}
+
+	if config.Logging.MaxSizeMB < 0 {
+		invalid("logging.maxSizeMB", "can't be negative (0 never rotates the log), is %d", config.Logging.MaxSizeMB)
+	}
+
+	if config.LoopRefreshTime <= 0 {
+		invalid("loopRefreshTime", "has to be greater than 0, is %g", config.LoopRefreshTime)
+	}
+
+	if config.MaxRequestsPerMinute < 0 {
+		invalid("maxRequestsPerMinute", "can't be negative (0 turns the limit off), is %d", config.MaxRequestsPerMinute)
+	}
+
+	if !slices.Contains(playlistEditPolicies, config.PlaylistEditPolicy) {
+		invalid("playlistEditPolicy", "has to be one of %v, is '%s'", playlistEditPolicies, config.PlaylistEditPolicy)
+	}
+
+	for index, pool := range config.Pools {
+		if pool.Trigger == "" {
+			invalid(fmt.Sprintf("pools[%d].trigger", index), "can't be empty")
+		} else if !isPoolURI(pool.Trigger) {
+			invalid(fmt.Sprintf("pools[%d].trigger", index), "has to be a playlist, album, artist, show or collection uri, is '%s'", pool.Trigger)
+		}
+
+		if len(pool.Sources) == 0 {
+			invalid(fmt.Sprintf("pools[%d].sources", index), "has to contain at least one source")
+		}
+
+		for sourceIndex, source := range pool.Sources {
+			if source.URI == "" {
+				invalid(fmt.Sprintf("pools[%d].sources[%d].uri", index, sourceIndex), "can't be empty")
+			} else if !isPoolURI(source.URI) {
+				invalid(fmt.Sprintf("pools[%d].sources[%d].uri", index, sourceIndex), "has to be a playlist, album, artist, show or collection uri, is '%s'", source.URI)
+			}
+
+			if source.Weight <= 0 {
+				invalid(fmt.Sprintf("pools[%d].sources[%d].weight", index, sourceIndex), "has to be greater than 0, is %g", source.Weight)
+			}
+		}
+	}
+
+	if config.PreviousTrackBuffer < 0 {
+		invalid("previousTrackBuffer", "can't be negative, is %d", config.PreviousTrackBuffer)
+	}
+
+	if _, err := schedule.New(config.Schedule); err != nil {
+		problems = append(problems, fmt.Errorf("'schedule': %s", err.Error()))
+	}
+
+	// Spotify only adds up to 100 tracks per request
+	if config.ShufflePlaylistSize < 2 || config.ShufflePlaylistSize > 100 {
+		invalid("shufflePlaylistSize", "has to be between 2 and 100, is %d", config.ShufflePlaylistSize)
+	} else if config.PreviousTrackBuffer >= 0 && config.ShufflePlaylistSize+config.PreviousTrackBuffer+1 > 100 {
+		// the shuffle playlist holds the previous tracks and the current one on top of its size, which has to fit into one request
+		invalid("previousTrackBuffer", "plus shufflePlaylistSize plus the current track can't be more than 100 tracks, is %d", config.ShufflePlaylistSize+config.PreviousTrackBuffer+1)
+	}
+
+	// the .env is only optional if the envs are already set
+	if !FileExists(config.envPath) && os.Getenv("SPOTIFY_ID") == "" {
+		invalid("paths.env", "doesn't exist (%s) and SPOTIFY_ID isn't set", config.envPath)
+	}
+
+	for _, path := range [][2]string{
+		{"paths.history", config.HistoryPath},
+		{"paths.log", config.LogPath},
+		{"paths.session", config.SessionPath},
+		{"paths.shufflePlaylist", config.ShufflePlaylistPath},
+		{"paths.token", config.TokenPath},
+	} {
+		if fileInfo, err := os.Stat(path[1]); err == nil && fileInfo.IsDir() {
+			invalid(path[0], "has to be a file, but is a directory (%s)", path[1])
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config;\n%w", errors.Join(problems...))
+	}
+
+	return nil
+}
+
+// isPoolURI checks if the URI is of a context, that can be used in a pool
+func isPoolURI(uri string) bool {
+	for _, prefix := range poolURIPrefixes {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+
+	// the Liked Songs are spotify:user:[ID]:collection
+	return strings.HasPrefix(uri, "spotify:user:") && strings.HasSuffix(uri, ":collection")
+}
@@ -0,0 +1,136 @@
+package util
+
+// <---------------------------------------------------------------------------------------------------->
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// <---------------------------------------------------------------------------------------------------->
+
+func TestValidateConfig(t *testing.T) {
+	// without a .env next to the config, the envs have to be set
+	t.Setenv("SPOTIFY_ID", "id")
+
+	tests := []struct {
+		name string
+		// configJSON gets written as config.json, missing keys keep their default
+		configJSON   string
+		expectedKeys []string
+	}{
+		{
+			name:       "defaults",
+			configJSON: `{}`,
+		},
+		{
+			name: "pool of an artist and the Liked Songs",
+			configJSON: `{"pools": [{"name": "mix", "trigger": "spotify:playlist:trigger", "sources": [
+				{"uri": "spotify:artist:source", "weight": 2},
+				{"uri": "spotify:user:me:collection", "weight": 1}
+			]}]}`,
+		},
+		{
+			name: "pool uris",
+			configJSON: `{"pools": [{"name": "mix", "trigger": "spotify:track:trigger", "sources": [
+				{"uri": "", "weight": 1},
+				{"uri": "spotify:user:me", "weight": 0}
+			]}]}`,
+			expectedKeys: []string{"pools[0].trigger", "pools[0].sources[0].uri", "pools[0].sources[1].uri", "pools[0].sources[1].weight"},
+		},
+		{
+			name:         "pool without sources",
+			configJSON:   `{"pools": [{"name": "mix", "trigger": "spotify:album:trigger", "sources": []}]}`,
+			expectedKeys: []string{"pools[0].sources"},
+		},
+		{
+			name:         "context rule without conditions",
+			configJSON:   `{"contextRules": [{"action": "skip"}]}`,
+			expectedKeys: []string{"contextRules[0].action", "contextRules[0]"},
+		},
+		{
+			name:         "previous tracks don't fit into the shuffle playlist",
+			configJSON:   `{"previousTrackBuffer": 3, "shufflePlaylistSize": 99}`,
+			expectedKeys: []string{"previousTrackBuffer"},
+		},
+		{
+			name:         "directory as a path",
+			configJSON:   `{"paths": {"history": "."}}`,
+			expectedKeys: []string{"paths.history"},
+		},
+		{
+			name:         "every problem is listed",
+			configJSON:   `{"callbackPort": ":99999", "logging": {"level": "loud"}, "loopRefreshTime": 0, "playlistEditPolicy": "keep", "shufflePlaylistSize": 1}`,
+			expectedKeys: []string{"callbackPort", "logging.level", "loopRefreshTime", "playlistEditPolicy", "shufflePlaylistSize"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+
+			err := os.WriteFile(configPath, []byte(test.configJSON), 0644)
+			if err != nil {
+				t.Fatalf("couldn't write config: %s", err.Error())
+			}
+
+			config, err := importConfig(configPath)
+			if err != nil {
+				t.Fatalf("importConfig returned an error: %s", err.Error())
+			}
+
+			resolvePaths(&config, filepath.Dir(configPath))
+
+			var problems []error
+
+			// all problems are joined behind a single line
+			if err := validateConfig(&config); err != nil {
+				joined, ok := errors.Unwrap(err).(interface{ Unwrap() []error })
+				if !ok {
+					t.Fatalf("got %v, expected the problems joined", err)
+				}
+
+				problems = joined.Unwrap()
+			}
+
+			var keys []string
+
+			for _, problem := range problems {
+				keys = append(keys, strings.Split(problem.Error(), "'")[1])
+			}
+
+			if !slices.Equal(keys, test.expectedKeys) {
+				t.Errorf("got problems with %v, expected %v", keys, test.expectedKeys)
+			}
+		})
+	}
+}
+
+func TestIsPoolURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected bool
+	}{
+		{uri: "spotify:album:id", expected: true},
+		{uri: "spotify:artist:id", expected: true},
+		{uri: "spotify:playlist:id", expected: true},
+		{uri: "spotify:show:id", expected: true},
+		{uri: "spotify:user:id:collection", expected: true},
+		{uri: "spotify:user:id", expected: false},
+		{uri: "spotify:track:id", expected: false},
+		{uri: "spotify:episode:id", expected: false},
+		{uri: "https://open.spotify.com/playlist/id", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uri, func(t *testing.T) {
+			if isPoolURI(test.uri) != test.expected {
+				t.Errorf("got %t, expected %t", !test.expected, test.expected)
+			}
+		})
+	}
+}